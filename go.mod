@@ -7,9 +7,10 @@ require (
 	github.com/lib/pq v1.10.9
 )
 
-require github.com/joho/godotenv v1.5.1
-
 require (
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-	golang.org/x/crypto v0.32.0 // indirect
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.32.0
 )
+
+require golang.org/x/sys v0.29.0 // indirect
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// handleDeleteAccount schedules the caller's account for deletion. Their
+// chirps disappear and every token is revoked at once, but logging in again
+// within the grace period restores everything.
+func (a *apiConfig) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	deleted, err := a.softDeleteUser(userID)
+	if err != nil {
+		log.Printf("could not delete user %v: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "could not delete account")
+		return
+	}
+	if !deleted {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	a.auditSelf(r, auditAccountDelete, outcomeSuccess, userID, "")
+
+	response := struct {
+		RestoreUntil time.Time `json:"restore_until"`
+	}{RestoreUntil: time.Now().Add(a.accountDeletionGrace).UTC()}
+	respondWithJSON(w, http.StatusAccepted, response)
+}
+
+func (a *apiConfig) softDeleteUser(userID uuid.UUID) (bool, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("could not begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	qtx := a.dbQueries.WithTx(tx)
+
+	deleted, err := qtx.SoftDeleteUser(context.Background(), userID)
+	if err != nil || deleted == 0 {
+		return false, err
+	}
+	err = qtx.RevokeAllUserTokens(context.Background(), userID)
+	if err != nil {
+		return false, err
+	}
+	err = qtx.RevokeAllPersonalAccessTokens(context.Background(), userID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// restoreAccount cancels the deletion of an account whose owner logged in
+// again. It fails with sql.ErrNoRows once the grace period is over.
+func (a *apiConfig) restoreAccount(userID uuid.UUID) (database.User, error) {
+	restoreParams := database.RestoreUserParams{ID: userID, GraceSeconds: int32(a.accountDeletionGrace.Seconds())}
+	user, err := a.dbQueries.RestoreUser(context.Background(), restoreParams)
+	if err != nil {
+		return database.User{}, err
+	}
+	log.Printf("restored account %v", userID)
+	return user, nil
+}
+
+// purgeDeletedAccounts removes accounts whose grace period has run out,
+// along with everything that belongs to them, every interval.
+func (a *apiConfig) purgeDeletedAccounts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := a.dbQueries.PurgeDeletedUsers(context.Background(), int32(a.accountDeletionGrace.Seconds()))
+		if err != nil {
+			log.Printf("could not purge deleted accounts: %v", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("purged %d deleted accounts", purged)
+		}
+	}
+}
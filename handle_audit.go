@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Audit event names.
+const (
+	auditLogin          = "login"
+	auditLoginMFA       = "login.mfa"
+	auditTokenRefresh   = "token.refresh"
+	auditTokenRevoke    = "token.revoke"
+	auditPasswordChange = "password.change"
+	auditPasswordReset  = "password.reset"
+	auditEmailChange    = "email.change"
+	auditChirpyRed      = "chirpy_red.upgrade"
+	auditSessionRevoke  = "session.revoke"
+	auditLogoutAll      = "session.revoke_all"
+	auditPATCreate      = "pat.create"
+	auditPATDelete      = "pat.delete"
+	auditTOTPEnable     = "mfa.enable"
+	auditTOTPDisable    = "mfa.disable"
+	auditRecoveryCode   = "mfa.recovery_code"
+	auditRoleChange     = "role.change"
+	auditAccountDelete  = "account.delete"
+	auditAccountRestore = "account.restore"
+	auditOAuthGrant     = "oauth.grant"
+	auditOAuthRevoke    = "oauth.revoke"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// auditEntry describes what happened to an account. UserID is the account
+// the event is about and ActorID whoever caused it; either may be unknown,
+// for example on a failed login for an email that has no account.
+type auditEntry struct {
+	Event   string
+	Outcome string
+	UserID  uuid.UUID
+	ActorID uuid.UUID
+	Details string
+}
+
+// audit appends entry to the audit log along with the client of r. The log
+// must never stand in the way of the action itself, so failures are only
+// logged.
+func (a *apiConfig) audit(r *http.Request, entry auditEntry) {
+	params := database.CreateAuditEventParams{
+		Event:     entry.Event,
+		Outcome:   entry.Outcome,
+		UserID:    uuid.NullUUID{UUID: entry.UserID, Valid: entry.UserID != uuid.Nil},
+		ActorID:   uuid.NullUUID{UUID: entry.ActorID, Valid: entry.ActorID != uuid.Nil},
+		IpAddress: a.clientIP(r),
+		UserAgent: r.UserAgent(),
+		Details:   entry.Details,
+	}
+	err := a.dbQueries.CreateAuditEvent(context.Background(), params)
+	if err != nil {
+		log.Printf("could not write audit event %s: %v", entry.Event, err)
+	}
+}
+
+// auditSelf records an event a user caused on their own account.
+func (a *apiConfig) auditSelf(r *http.Request, event, outcome string, userID uuid.UUID, details string) {
+	a.audit(r, auditEntry{Event: event, Outcome: outcome, UserID: userID, ActorID: userID, Details: details})
+}
+
+type auditEventEntry struct {
+	Id        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Event     string     `json:"event"`
+	Outcome   string     `json:"outcome"`
+	UserID    *uuid.UUID `json:"user_id"`
+	ActorID   *uuid.UUID `json:"actor_id"`
+	IpAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	Details   string     `json:"details,omitempty"`
+}
+
+func newAuditEventEntries(events []database.AuditEvent) []auditEventEntry {
+	entries := make([]auditEventEntry, 0, len(events))
+	for _, event := range events {
+		entry := auditEventEntry{
+			Id:        event.ID,
+			CreatedAt: event.CreatedAt,
+			Event:     event.Event,
+			Outcome:   event.Outcome,
+			IpAddress: event.IpAddress,
+			UserAgent: event.UserAgent,
+			Details:   event.Details,
+		}
+		if event.UserID.Valid {
+			entry.UserID = &event.UserID.UUID
+		}
+		if event.ActorID.Valid {
+			entry.ActorID = &event.ActorID.UUID
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parseAuditLimit reads the limit query parameter, answering the request
+// and returning false when it is invalid.
+func parseAuditLimit(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultAuditLimit, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxAuditLimit {
+		respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+		return 0, false
+	}
+	return int32(limit), true
+}
+
+// handleGetAccountActivity lists the most recent security events of the
+// caller's account so they can spot activity that wasn't theirs.
+func (a *apiConfig) handleGetAccountActivity(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	limit, ok := parseAuditLimit(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.ListUserAuditEventsParams{
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Limit:  limit,
+	}
+	events, err := a.dbQueries.ListUserAuditEvents(context.Background(), params)
+	if err != nil {
+		log.Printf("could not list audit events: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list activity")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAuditEventEntries(events))
+}
+
+// handleGetAuditEvents lets admins search the whole audit log. Every filter
+// is optional: user_id, event, outcome, ip, and since and until as RFC 3339
+// times.
+func (a *apiConfig) handleGetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, ok := parseAuditLimit(w, r)
+	if !ok {
+		return
+	}
+	params := database.ListAuditEventsParams{MaxResults: limit}
+
+	if raw := query.Get("user_id"); raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid user_id")
+			return
+		}
+		params.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+	if outcome := query.Get("outcome"); outcome != "" {
+		if outcome != outcomeSuccess && outcome != outcomeFailure {
+			respondWithError(w, http.StatusBadRequest, "outcome must be success or failure")
+			return
+		}
+		params.Outcome = sql.NullString{String: outcome, Valid: true}
+	}
+	if event := query.Get("event"); event != "" {
+		params.Event = sql.NullString{String: event, Valid: true}
+	}
+	if ip := query.Get("ip"); ip != "" {
+		params.IpAddress = sql.NullString{String: ip, Valid: true}
+	}
+
+	since, ok := parseTimeParam(w, r, "since")
+	if !ok {
+		return
+	}
+	until, ok := parseTimeParam(w, r, "until")
+	if !ok {
+		return
+	}
+	params.Since, params.Until = since, until
+
+	events, err := a.dbQueries.ListAuditEvents(context.Background(), params)
+	if err != nil {
+		log.Printf("could not list audit events: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list audit events")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAuditEventEntries(events))
+}
+
+// parseTimeParam reads an optional RFC 3339 time from the query, answering
+// the request and returning false when it is invalid.
+func parseTimeParam(w http.ResponseWriter, r *http.Request, name string) (sql.NullTime, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return sql.NullTime{}, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid "+name+", expected an RFC 3339 time")
+		return sql.NullTime{}, false
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, true
+}
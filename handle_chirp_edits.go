@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	errNotChirpAuthor   = errors.New("not the author of the chirp")
+	errEditWindowClosed = errors.New("edit window has passed")
+	errEditRechirp      = errors.New("rechirps have no body to edit")
+)
+
+// handleUpdateChirp lets the author replace the body of a chirp within
+// the edit window. The body it replaces is kept as a revision.
+func (a *apiConfig) handleUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "incorrect chirp id")
+		return
+	}
+
+	var chirpRequest struct {
+		Body string `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&chirpRequest)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	body, err := cleanChirpBody(chirpRequest.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+		return
+	}
+
+	if !a.checkCanPost(w, userID) {
+		return
+	}
+
+	chirp, err := a.editChirp(chirpID, userID, body)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	if errors.Is(err, errNotChirpAuthor) {
+		respondWithError(w, http.StatusForbidden, "only the author can edit a chirp")
+		return
+	}
+	if errors.Is(err, errEditRechirp) {
+		respondWithError(w, http.StatusBadRequest, "rechirps cannot be edited")
+		return
+	}
+	if errors.Is(err, errEditWindowClosed) {
+		respondWithError(w, http.StatusForbidden, "chirps can only be edited within "+a.chirpEditWindow.String()+" of posting")
+		return
+	}
+	if err != nil {
+		log.Printf("could not edit chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not edit chirp")
+		return
+	}
+
+	entry := newChirpEntry(chirp)
+	a.fillChirpEntries(r, &entry)
+	respondWithJSON(w, http.StatusOK, entry)
+}
+
+// editChirp replaces the body of a chirp and records the old one in a
+// single transaction. The row is locked first so that concurrent edits
+// each keep the body they replaced.
+func (a *apiConfig) editChirp(chirpID, userID uuid.UUID, body string) (database.Chirp, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := a.dbQueries.WithTx(tx)
+
+	current, err := qtx.GetChirpForUpdate(context.Background(), chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if current.UserID != userID {
+		return database.Chirp{}, errNotChirpAuthor
+	}
+	if current.RechirpOf.Valid {
+		return database.Chirp{}, errEditRechirp
+	}
+	if current.Body == body {
+		return current, nil
+	}
+
+	updateParams := database.UpdateChirpParams{
+		ID:                chirpID,
+		Body:              body,
+		EditWindowSeconds: int32(a.chirpEditWindow.Seconds()),
+	}
+	updated, err := qtx.UpdateChirp(context.Background(), updateParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Chirp{}, errEditWindowClosed
+	}
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	revisionParams := database.CreateChirpRevisionParams{
+		ChirpID:   chirpID,
+		Body:      current.Body,
+		WrittenAt: current.UpdatedAt,
+	}
+	err = qtx.CreateChirpRevision(context.Background(), revisionParams)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	return updated, tx.Commit()
+}
+
+type chirpRevisionEntry struct {
+	Body       string    `json:"body"`
+	WrittenAt  time.Time `json:"written_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// handleGetChirpRevisions lists the earlier bodies of a chirp, newest
+// first. The current body is the chirp itself.
+func (a *apiConfig) handleGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "incorrect chirp id")
+		return
+	}
+
+	// revisions of chirps by deleted accounts are hidden with the chirp
+	_, err = a.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+
+	revisions, err := a.dbQueries.ListChirpRevisions(context.Background(), chirpID)
+	if err != nil {
+		log.Printf("could not list chirp revisions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list revisions")
+		return
+	}
+
+	entries := make([]chirpRevisionEntry, 0, len(revisions))
+	for _, revision := range revisions {
+		entries = append(entries, chirpRevisionEntry{
+			Body:       revision.Body,
+			WrittenAt:  revision.WrittenAt,
+			ReplacedAt: revision.ReplacedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, entries)
+}
@@ -2,16 +2,22 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ChernakovEgor/chirpy/internal/auth"
 	"github.com/ChernakovEgor/chirpy/internal/database"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type chirpEntry struct {
@@ -20,87 +26,353 @@ type chirpEntry struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"body"`
 	UserId    uuid.UUID `json:"user_id"`
+	Edited    bool      `json:"edited"`
+	// InReplyTo is null for chirps that start a conversation, and for
+	// replies to chirps that were deleted.
+	InReplyTo  *uuid.UUID `json:"in_reply_to"`
+	RootId     uuid.UUID  `json:"root_id"`
+	ReplyCount int32      `json:"reply_count"`
+	LikeCount  int32      `json:"like_count"`
+	// LikedByMe is only set for authenticated callers.
+	LikedByMe *bool `json:"liked_by_me,omitempty"`
+	// RechirpOf is set for rechirps, which have an empty body, and QuoteOf
+	// for quotes. Rechirped and Quoted hold the chirps they refer to, or
+	// null when those were deleted or are hidden. They are only filled in
+	// one level deep.
+	RechirpOf    *uuid.UUID  `json:"rechirp_of"`
+	QuoteOf      *uuid.UUID  `json:"quote_of"`
+	Rechirped    *chirpEntry `json:"rechirped"`
+	Quoted       *chirpEntry `json:"quoted"`
+	RechirpCount int32       `json:"rechirp_count"`
+	QuoteCount   int32       `json:"quote_count"`
+}
+
+// newChirpEntry describes chirp. Only edits touch updated_at, so a chirp
+// was edited when it was updated after it was created.
+func newChirpEntry(chirp database.Chirp) chirpEntry {
+	entry := chirpEntry{
+		Id:         chirp.ID,
+		CreatedAt:  chirp.CreatedAt,
+		UpdatedAt:  chirp.UpdatedAt,
+		Body:       chirp.Body,
+		UserId:     chirp.UserID,
+		Edited:     chirp.UpdatedAt.After(chirp.CreatedAt),
+		RootId:     chirp.RootID,
+		ReplyCount: chirp.ReplyCount,
+		LikeCount:  chirp.LikeCount,
+
+		RechirpCount: chirp.RechirpCount,
+		QuoteCount:   chirp.QuoteCount,
+	}
+	if chirp.InReplyTo.Valid {
+		entry.InReplyTo = &chirp.InReplyTo.UUID
+	}
+	if chirp.RechirpOf.Valid {
+		entry.RechirpOf = &chirp.RechirpOf.UUID
+	}
+	if chirp.QuoteOf.Valid {
+		entry.QuoteOf = &chirp.QuoteOf.UUID
+	}
+	return entry
+}
+
+// fillChirpEntries adds what newChirpEntry can't know from the row alone:
+// the chirps that entries rechirp or quote, and liked_by_me for the caller.
+func (a *apiConfig) fillChirpEntries(r *http.Request, entries ...*chirpEntry) {
+	embedded := a.embedSharedChirps(entries...)
+	a.setLikedByMe(r, slices.Concat(entries, embedded)...)
 }
 
 func (a *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
 	var chirpRequest struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Fatalf("could not get token from headers: %v", err)
+	userID := principalFrom(r).UserID
+	if !a.checkCanPost(w, userID) {
+		return
 	}
 
-	userID, err := auth.ValidateJWT(token, a.jwtSecret)
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&chirpRequest)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "invalid JWT token")
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
 		return
 	}
 
-	b, err := io.ReadAll(r.Body)
+	body, err := cleanChirpBody(chirpRequest.Body)
 	if err != nil {
-		log.Fatalf("could not read body: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+		return
 	}
 
-	err = json.Unmarshal(b, &chirpRequest)
-	if err != nil {
-		log.Fatalf("could not unmarshal request: %v", err)
+	chirpParams := database.CreateChirpParams{Body: body, UserID: userID}
+	if chirpRequest.InReplyTo != nil {
+		// chirps of deleted accounts can't be replied to
+		parent, err := a.getSharedChirp(*chirpRequest.InReplyTo)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "in_reply_to is not an existing chirp")
+			return
+		}
+		chirpParams.InReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+	if chirpRequest.QuoteOf != nil {
+		quoted, err := a.getSharedChirp(*chirpRequest.QuoteOf)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "quote_of is not an existing chirp")
+			return
+		}
+		chirpParams.QuoteOf = uuid.NullUUID{UUID: quoted.ID, Valid: true}
 	}
 
-	chirpParams := database.CreateChirpParams{Body: chirpRequest.Body, UserID: userID}
 	chirp, err := a.dbQueries.CreateChirp(context.Background(), chirpParams)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		// the chirp replied to was deleted in the meantime, which
+		// chirps_set_root reports the same way as the foreign key
+		respondWithError(w, http.StatusBadRequest, "in_reply_to is not an existing chirp")
+		return
+	}
+	if err != nil {
+		log.Printf("could not create chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not create chirp")
+		return
+	}
+
+	entry := newChirpEntry(chirp)
+	a.fillChirpEntries(r, &entry)
+	respondWithJSON(w, http.StatusCreated, entry)
+}
+
+// checkCanPost answers the request and returns false unless userID may
+// write chirps.
+func (a *apiConfig) checkCanPost(w http.ResponseWriter, userID uuid.UUID) bool {
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
 	if err != nil {
-		log.Fatalf("could not create chirp: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid JWT token")
+		return false
+	}
+	if user.DeletedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "account is scheduled for deletion")
+		return false
 	}
 
-	chirpResponse := chirpEntry{
-		Id:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserId:    chirp.UserID,
+	if a.isRestricted(user, restrictPostChirps) {
+		respondWithError(w, http.StatusForbidden, "verify your email address to post chirps")
+		return false
 	}
+	return true
+}
+
+const (
+	defaultChirpPageSize = 50
+	maxChirpPageSize     = 100
+	// maxChirpAuthors caps how many authors one listing may filter on.
+	maxChirpAuthors = 100
+)
 
-	respondWithJSON(w, http.StatusCreated, chirpResponse)
+type chirpPage struct {
+	Chirps     []chirpEntry `json:"chirps"`
+	NextCursor *string      `json:"next_cursor"`
 }
 
+// handleGetChirps lists chirps a page at a time. Pages are keyed on
+// (created_at, id), so the cursor stays valid while new chirps are posted
+// and every page costs the same no matter how deep the client has read.
+// The body stays a plain array of chirps, so the cursor of the next page,
+// if there is one, is sent in the X-Next-Cursor header.
+// See parseChirpFilters for the filters it accepts.
 func (a *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
-	authorIdString := r.URL.Query().Get("author_id")
+	query := r.URL.Query()
+
+	limit, ok := parsePageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	sortOrder := query.Get("sort")
+	if sortOrder != "" && sortOrder != "asc" && sortOrder != "desc" {
+		respondWithError(w, http.StatusBadRequest, "sort must be asc or desc")
+		return
+	}
+
+	params, ok := parseChirpFilters(w, r)
+	if !ok {
+		return
+	}
+	// one extra row tells whether there is another page
+	params.MaxResults = int32(limit + 1)
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		createdAt, id, err := decodeChirpCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		params.AfterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
 	var chirps []database.Chirp
 	var err error
+	if sortOrder == "desc" {
+		chirps, err = a.dbQueries.ListChirpsDesc(context.Background(), database.ListChirpsDescParams(params))
+	} else {
+		chirps, err = a.dbQueries.ListChirpsAsc(context.Background(), params)
+	}
+	if err != nil {
+		log.Printf("could not list chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list chirps")
+		return
+	}
+
+	if len(chirps) > limit {
+		chirps = chirps[:limit]
+		last := chirps[limit-1]
+		w.Header().Set("X-Next-Cursor", encodeChirpCursor(last.CreatedAt, last.ID))
+	}
+	entries := make([]chirpEntry, 0, len(chirps))
+	for _, chirp := range chirps {
+		entries = append(entries, newChirpEntry(chirp))
+	}
+	a.fillChirpEntries(r, entryRefs(entries)...)
+
+	respondWithJSON(w, http.StatusOK, entries)
+}
 
-	if authorIdString == "" {
-		chirps, err = a.dbQueries.GetAllChirps(context.Background())
+// parseChirpFilters reads the filters of a chirp listing from the query:
+//
+//   - author_id: one or more authors, repeated or comma separated
+//   - since, until: RFC 3339 times bounding created_at
+//   - contains: case-insensitive substring of the body
+//   - has_media: whether the body links to something
+//   - chirpy_red: only chirps by Chirpy Red members
+//
+// It answers the request with 400 and returns false on bad input.
+func parseChirpFilters(w http.ResponseWriter, r *http.Request) (database.ListChirpsAscParams, bool) {
+	query := r.URL.Query()
+	var params database.ListChirpsAscParams
+
+	for _, value := range query["author_id"] {
+		for _, raw := range splitList(value) {
+			authorID, err := uuid.Parse(raw)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid author_id %q", raw))
+				return params, false
+			}
+			params.AuthorIds = append(params.AuthorIds, authorID)
+		}
+	}
+	if len(params.AuthorIds) > maxChirpAuthors {
+		respondWithError(w, http.StatusBadRequest, "too many author_id values")
+		return params, false
+	}
+
+	var ok bool
+	if params.Since, ok = parseTimeParam(w, r, "since"); !ok {
+		return params, false
+	}
+	if params.Until, ok = parseTimeParam(w, r, "until"); !ok {
+		return params, false
+	}
+	if params.Since.Valid && params.Until.Valid && !params.Since.Time.Before(params.Until.Time) {
+		respondWithError(w, http.StatusBadRequest, "since must be before until")
+		return params, false
+	}
+
+	if contains := query.Get("contains"); contains != "" {
+		if len(contains) > maxChirpLength {
+			respondWithError(w, http.StatusBadRequest, "contains is longer than a chirp")
+			return params, false
+		}
+		params.Contains = sql.NullString{String: contains, Valid: true}
+	}
+
+	if raw := query.Get("has_media"); raw != "" {
+		hasMedia, err := strconv.ParseBool(raw)
 		if err != nil {
-			log.Fatalf("could not get chirps: %v", err)
+			respondWithError(w, http.StatusBadRequest, "has_media must be true or false")
+			return params, false
 		}
-	} else {
-		authorID, err := uuid.Parse(authorIdString)
+		params.HasMedia = sql.NullBool{Bool: hasMedia, Valid: true}
+	}
+
+	if raw := query.Get("chirpy_red"); raw != "" {
+		chirpyRed, err := strconv.ParseBool(raw)
 		if err != nil {
-			respondWithError(w, http.StatusNotFound, "invalid id")
-			return
+			respondWithError(w, http.StatusBadRequest, "chirpy_red must be true or false")
+			return params, false
 		}
-		chirps, err = a.dbQueries.GetChirpByAuthor(context.Background(), authorID)
+		params.ChirpyRedOnly = chirpyRed
 	}
 
-	sortOrder := r.URL.Query().Get("sort")
-	if sortOrder == "desc" {
-		slices.Reverse(chirps)
+	return params, true
+}
+
+// parsePageLimit reads the page size from the limit query parameter.
+// Larger pages than maxChirpPageSize are cut down to it.
+func parsePageLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultChirpPageSize, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		respondWithError(w, http.StatusBadRequest, "limit must be a positive number")
+		return 0, false
 	}
+	return min(n, maxChirpPageSize), true
+}
 
-	var chirpResponse []chirpEntry
-	for _, chirp := range chirps {
-		chirpResponse = append(chirpResponse, chirpEntry{chirp.ID, chirp.CreatedAt, chirp.UpdatedAt, chirp.Body, chirp.UserID})
+// encodeCursor joins the sort key of the last row of a page into an
+// opaque cursor.
+func encodeCursor(parts ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, ",")))
+}
+
+// decodeCursor splits a cursor made by encodeCursor back into its n parts.
+func decodeCursor(cursor string, n int) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(raw), ",")
+	if len(parts) != n {
+		return nil, errors.New("malformed cursor")
 	}
+	return parts, nil
+}
 
-	respondWithJSON(w, http.StatusOK, chirpResponse)
+// encodeChirpCursor makes an opaque cursor pointing just past the chirp
+// with the given key.
+func encodeChirpCursor(createdAt time.Time, id uuid.UUID) string {
+	return encodeCursor(createdAt.UTC().Format(time.RFC3339Nano), id.String())
+}
+
+func decodeChirpCursor(cursor string) (time.Time, uuid.UUID, error) {
+	parts, err := decodeCursor(cursor, 2)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	return parseChirpKey(parts[0], parts[1])
+}
+
+func parseChirpKey(createdAtPart, idPart string) (time.Time, uuid.UUID, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	return createdAt, id, nil
 }
 
 func (a *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request) {
 	chirpID := r.PathValue("chirpID")
-	log.Print(chirpID)
-	log.Print(uuid.Validate(chirpID))
 	chirpUUID, err := uuid.Parse(chirpID)
 	if err != nil {
 		respondWithError(w, 404, "Incorrect UUID string")
@@ -112,28 +384,14 @@ func (a *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirpResponse := chirpEntry{
-		chirp.ID,
-		chirp.CreatedAt,
-		chirp.UpdatedAt,
-		chirp.Body,
-		chirp.UserID,
-	}
-	respondWithJSON(w, http.StatusOK, chirpResponse)
+	entry := newChirpEntry(chirp)
+	a.fillChirpEntries(r, &entry)
+	respondWithJSON(w, http.StatusOK, entry)
 }
 
 func (a *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "token invalid")
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, a.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "token invalid")
-		return
-	}
+	caller := principalFrom(r)
+	userID := caller.UserID
 
 	chirpIdString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIdString)
@@ -149,14 +407,22 @@ func (a *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if chirp.UserID != userID {
+	// moderators may remove anyone's chirps, but not through delegated tokens
+	moderating := caller.isSession() && auth.HasRole(caller.Role, auth.RoleModerator)
+	if chirp.UserID != userID && !moderating {
 		respondWithError(w, http.StatusForbidden, "incorrect chirp id")
 		return
 	}
 
-	// delete chirp
-	deleteChirpParams := database.DeleteChirpParams{UserID: userID, ID: chirpID}
-	_, err = a.dbQueries.DeleteChirp(context.Background(), deleteChirpParams)
+	// delete chirp. Rechirps of it are deleted along with it, while quotes
+	// keep their quote_of and show the chirp as gone.
+	if chirp.UserID == userID {
+		deleteChirpParams := database.DeleteChirpParams{UserID: userID, ID: chirpID}
+		_, err = a.dbQueries.DeleteChirp(context.Background(), deleteChirpParams)
+	} else {
+		log.Printf("moderator %v deleted chirp %v by %v", userID, chirpID, chirp.UserID)
+		_, err = a.dbQueries.DeleteChirpByID(context.Background(), chirpID)
+	}
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "chirp not found")
 		return
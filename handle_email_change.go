@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/ChernakovEgor/chirpy/internal/mail"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// handleRequestEmailChange starts moving the caller's account to a new
+// address. Nothing changes until the link sent to the new address is
+// opened; the old address is told about the request.
+func (a *apiConfig) handleRequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	var body struct {
+		NewEmail string `json:"new_email"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	newEmail := strings.TrimSpace(body.NewEmail)
+	if newEmail == "" || !strings.Contains(newEmail, "@") {
+		respondWithError(w, http.StatusBadRequest, "invalid email address")
+		return
+	}
+
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid jwt token")
+		return
+	}
+
+	if !a.checkCurrentPassword(w, user, body.Password) {
+		a.auditSelf(r, auditEmailChange, outcomeFailure, user.ID, "current password rejected")
+		return
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		respondWithError(w, http.StatusBadRequest, "this is already your email address")
+		return
+	}
+
+	_, err = a.dbQueries.GetUserByEmail(context.Background(), newEmail)
+	if err == nil {
+		respondWithError(w, http.StatusConflict, "email address is already in use")
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("could not look up email: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not change email")
+		return
+	}
+
+	err = a.sendEmailChangeConfirmation(user.ID, newEmail)
+	if err != nil {
+		log.Printf("could not send email change confirmation: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not change email")
+		return
+	}
+	a.auditSelf(r, auditEmailChange, outcomeSuccess, user.ID, "requested change to "+newEmail)
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy email address is being changed",
+		Body:    fmt.Sprintf("Someone asked to move your Chirpy account to %s.\n\nThe change only happens once that address confirms it. If this wasn't you, reset your password right away.\n", newEmail),
+	}
+	err = a.mailer.Send(msg)
+	if err != nil {
+		log.Printf("could not send email change notice to user %v: %v", user.ID, err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *apiConfig) sendEmailChangeConfirmation(userID uuid.UUID, newEmail string) error {
+	token, err := auth.MakeRandomToken()
+	if err != nil {
+		return fmt.Errorf("could not create email change token: %v", err)
+	}
+
+	tokenParams := database.CreateEmailChangeTokenParams{
+		UserID:      userID,
+		NewEmail:    newEmail,
+		TokenPrefix: auth.TokenPrefix(token),
+		TokenHash:   auth.HashToken(token, a.tokenHashKey),
+	}
+	_, err = a.dbQueries.CreateEmailChangeToken(context.Background(), tokenParams)
+	if err != nil {
+		return fmt.Errorf("could not insert email change token: %v", err)
+	}
+
+	link := fmt.Sprintf("%s/confirm-email-change?token=%s", a.publicURL, url.QueryEscape(token))
+	msg := mail.Message{
+		To:      newEmail,
+		Subject: "Confirm your new Chirpy email address",
+		Body:    fmt.Sprintf("Confirm that this is the new email address of your Chirpy account by opening this link within 24 hours:\n\n%s\n", link),
+	}
+	return a.mailer.Send(msg)
+}
+
+func (a *apiConfig) handleConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	stored, err := a.findEmailChangeToken(body.Token)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired token")
+		return
+	}
+
+	user, err := a.changeEmail(stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired token")
+		return
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondWithError(w, http.StatusConflict, "email address is already in use")
+		return
+	}
+	if err != nil {
+		log.Printf("could not change email: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not change email")
+		return
+	}
+	a.auditSelf(r, auditEmailChange, outcomeSuccess, user.ID, "confirmed change to "+user.Email)
+
+	response := userResponse{
+		Id:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		IsVerified:  user.EmailVerifiedAt.Valid,
+		Role:        user.Role,
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// changeEmail applies a confirmed change and cancels any other pending
+// change for the same account.
+func (a *apiConfig) changeEmail(stored database.EmailChangeToken) (database.User, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return database.User{}, fmt.Errorf("could not begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	qtx := a.dbQueries.WithTx(tx)
+
+	used, err := qtx.UseEmailChangeToken(context.Background(), stored.ID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if used == 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+
+	err = qtx.InvalidateEmailChangeTokens(context.Background(), stored.UserID)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	emailParams := database.UpdateEmailParams{ID: stored.UserID, Email: stored.NewEmail}
+	user, err := qtx.UpdateEmail(context.Background(), emailParams)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+func (a *apiConfig) findEmailChangeToken(token string) (database.EmailChangeToken, error) {
+	candidates, err := a.dbQueries.GetEmailChangeTokensByPrefix(context.Background(), auth.TokenPrefix(token))
+	if err != nil {
+		return database.EmailChangeToken{}, err
+	}
+
+	for _, candidate := range candidates {
+		if auth.CheckTokenHash(token, candidate.TokenHash, a.tokenHashKey) {
+			return candidate, nil
+		}
+	}
+	return database.EmailChangeToken{}, sql.ErrNoRows
+}
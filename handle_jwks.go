@@ -0,0 +1,13 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handleJWKS publishes the public signing keys so other services can verify
+// access tokens without sharing a secret.
+func (a *apiConfig) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, a.jwtKeys.JWKS())
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// handleLikeChirp likes a chirp for the caller. Liking a chirp twice is
+// not an error, and liking a rechirp likes the original.
+func (a *apiConfig) handleLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "incorrect chirp id")
+		return
+	}
+
+	chirp, err := a.getSharedChirp(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+
+	likeParams := database.LikeChirpParams{UserID: userID, ChirpID: chirp.ID}
+	_, err = a.dbQueries.LikeChirp(context.Background(), likeParams)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		// the chirp was deleted in the meantime
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("could not like chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not like chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUnlikeChirp takes the caller's like back. Chirps that were not
+// liked, or no longer exist, are left as they are. Like handleLikeChirp,
+// unliking a rechirp unlikes the original.
+func (a *apiConfig) handleUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "incorrect chirp id")
+		return
+	}
+
+	chirp, err := a.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err == nil && chirp.RechirpOf.Valid {
+		chirpID = chirp.RechirpOf.UUID
+	}
+
+	unlikeParams := database.UnlikeChirpParams{UserID: userID, ChirpID: chirpID}
+	_, err = a.dbQueries.UnlikeChirp(context.Background(), unlikeParams)
+	if err != nil {
+		log.Printf("could not unlike chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not unlike chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetUserLikes lists the chirps a user liked, most recently liked
+// first.
+func (a *apiConfig) handleGetUserLikes(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	limit, ok := parsePageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.ListUserLikesParams{UserID: userID, MaxResults: int32(limit + 1)}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		likedAt, chirpID, err := decodeChirpCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		params.AfterLikedAt = sql.NullTime{Time: likedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: chirpID, Valid: true}
+	}
+
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil || user.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	likes, err := a.dbQueries.ListUserLikes(context.Background(), params)
+	if err != nil {
+		log.Printf("could not list likes: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list likes")
+		return
+	}
+
+	page := chirpPage{Chirps: make([]chirpEntry, 0, len(likes))}
+	if len(likes) > limit {
+		likes = likes[:limit]
+		last := likes[limit-1]
+		// pages of likes are keyed on when the chirp was liked
+		next := encodeChirpCursor(last.LikedAt, last.Chirp.ID)
+		page.NextCursor = &next
+	}
+	for _, like := range likes {
+		page.Chirps = append(page.Chirps, newChirpEntry(like.Chirp))
+	}
+	a.fillChirpEntries(r, entryRefs(page.Chirps)...)
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// setLikedByMe fills in LikedByMe for authenticated callers, with a single
+// query for all of entries. Anonymous callers get no liked_by_me field.
+func (a *apiConfig) setLikedByMe(r *http.Request, entries ...*chirpEntry) {
+	caller := principalFrom(r)
+	if caller.isAnonymous() || len(entries) == 0 {
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.Id)
+	}
+	params := database.ListLikedChirpIDsParams{UserID: caller.UserID, ChirpIds: ids}
+	liked, err := a.dbQueries.ListLikedChirpIDs(context.Background(), params)
+	if err != nil {
+		log.Printf("could not look up liked chirps: %v", err)
+		return
+	}
+
+	likedSet := make(map[uuid.UUID]bool, len(liked))
+	for _, id := range liked {
+		likedSet[id] = true
+	}
+	for _, entry := range entries {
+		likedByMe := likedSet[entry.Id]
+		entry.LikedByMe = &likedByMe
+	}
+}
+
+// entryRefs points at each of entries so they can be filled in in place.
+func entryRefs(entries []chirpEntry) []*chirpEntry {
+	refs := make([]*chirpEntry, 0, len(entries))
+	for i := range entries {
+		refs = append(refs, &entries[i])
+	}
+	return refs
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// likesDB is an in-memory stand-in for the few queries the like handlers
+// run. It is registered as a database/sql driver so the generated queries
+// are exercised unchanged.
+type likesDB struct {
+	chirps map[uuid.UUID]database.Chirp
+	likes  map[[2]uuid.UUID]bool
+}
+
+var likesDBs = map[string]*likesDB{}
+
+type likesDriver struct{}
+
+func (likesDriver) Open(name string) (driver.Conn, error) {
+	return likesConn{likesDBs[name]}, nil
+}
+
+func init() {
+	sql.Register("likes-test", likesDriver{})
+}
+
+type likesConn struct{ db *likesDB }
+
+func (c likesConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c likesConn) Close() error                        { return nil }
+func (c likesConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+var queryName = regexp.MustCompile(`-- name: (\w+)`)
+
+func (c likesConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if name := queryName.FindStringSubmatch(query)[1]; name != "GetChirpByID" {
+		return nil, errors.New("unexpected query " + name)
+	}
+	chirp, ok := c.db.chirps[argUUID(args[0])]
+	if !ok {
+		return &chirpRows{}, nil
+	}
+	return &chirpRows{chirps: []database.Chirp{chirp}}, nil
+}
+
+func (c likesConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	key := [2]uuid.UUID{argUUID(args[0]), argUUID(args[1])}
+	switch name := queryName.FindStringSubmatch(query)[1]; name {
+	case "LikeChirp":
+		if c.db.likes[key] {
+			return driver.RowsAffected(0), nil
+		}
+		c.db.likes[key] = true
+		return driver.RowsAffected(1), nil
+	case "UnlikeChirp":
+		if !c.db.likes[key] {
+			return driver.RowsAffected(0), nil
+		}
+		delete(c.db.likes, key)
+		return driver.RowsAffected(1), nil
+	default:
+		return nil, errors.New("unexpected query " + name)
+	}
+}
+
+func argUUID(arg driver.NamedValue) uuid.UUID {
+	id, _ := uuid.Parse(arg.Value.(string))
+	return id
+}
+
+type chirpRows struct{ chirps []database.Chirp }
+
+func (r *chirpRows) Columns() []string {
+	return []string{"id", "created_at", "updated_at", "body", "user_id", "in_reply_to", "root_id",
+		"reply_count", "like_count", "rechirp_of", "quote_of", "rechirp_count", "quote_count", "thread_path", "search_vector"}
+}
+
+func (r *chirpRows) Close() error { return nil }
+
+func (r *chirpRows) Next(dest []driver.Value) error {
+	if len(r.chirps) == 0 {
+		return io.EOF
+	}
+	c := r.chirps[0]
+	r.chirps = r.chirps[1:]
+	nullUUID := func(id uuid.NullUUID) driver.Value {
+		if !id.Valid {
+			return nil
+		}
+		return id.UUID.String()
+	}
+	copy(dest, []driver.Value{c.ID.String(), c.CreatedAt, c.UpdatedAt, c.Body, c.UserID.String(),
+		nullUUID(c.InReplyTo), c.RootID.String(), int64(c.ReplyCount), int64(c.LikeCount),
+		nullUUID(c.RechirpOf), nullUUID(c.QuoteOf), int64(c.RechirpCount), int64(c.QuoteCount), []byte("{}"), []byte("")})
+	return nil
+}
+
+func TestLikeAndUnlikeThroughRechirp(t *testing.T) {
+	now := time.Now()
+	original := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: uuid.New()}
+	original.RootID = original.ID
+	rechirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: uuid.New(),
+		RechirpOf: uuid.NullUUID{UUID: original.ID, Valid: true}}
+	rechirp.RootID = rechirp.ID
+
+	fake := &likesDB{
+		chirps: map[uuid.UUID]database.Chirp{original.ID: original, rechirp.ID: rechirp},
+		likes:  map[[2]uuid.UUID]bool{},
+	}
+	likesDBs[t.Name()] = fake
+	db, err := sql.Open("likes-test", t.Name())
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer db.Close()
+	a := &apiConfig{db: db, dbQueries: *database.New(db)}
+
+	userID := uuid.New()
+	call := func(handler http.HandlerFunc, method string) int {
+		r := httptest.NewRequest(method, "/api/chirps/"+rechirp.ID.String()+"/likes", nil)
+		r.SetPathValue("chirpID", rechirp.ID.String())
+		r = r.WithContext(context.WithValue(r.Context(), principalKey{}, principal{UserID: userID}))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	if code := call(a.handleLikeChirp, http.MethodPost); code != http.StatusNoContent {
+		t.Fatalf("like: got status %d", code)
+	}
+	if !fake.likes[[2]uuid.UUID{userID, original.ID}] || len(fake.likes) != 1 {
+		t.Fatalf("like through rechirp should like the original, got %v", fake.likes)
+	}
+
+	if code := call(a.handleUnlikeChirp, http.MethodDelete); code != http.StatusNoContent {
+		t.Fatalf("unlike: got status %d", code)
+	}
+	if len(fake.likes) != 0 {
+		t.Errorf("unlike through rechirp should unlike the original, got %v", fake.likes)
+	}
+}
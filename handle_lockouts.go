@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
+)
+
+// Failed logins are counted under two keys: the account that was targeted,
+// whether it exists or not, and the address the attempt came from.
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLoginThrottle answers the request and returns false while key is
+// backing off or locked out. Errors let the attempt through so a database
+// hiccup does not lock everybody out.
+func (a *apiConfig) checkLoginThrottle(w http.ResponseWriter, key string, policy auth.LockoutPolicy) bool {
+	throttle, err := a.dbQueries.GetLoginThrottle(context.Background(), key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	if err != nil {
+		log.Printf("could not get login throttle: %v", err)
+		return true
+	}
+	if throttle.RetryAfterSeconds <= 0 {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(throttle.RetryAfterSeconds)))
+	if policy.LockedOut(int(throttle.Failures)) {
+		respondWithError(w, http.StatusLocked, "account temporarily locked")
+		return false
+	}
+	respondWithError(w, http.StatusTooManyRequests, "too many failed login attempts")
+	return false
+}
+
+func (a *apiConfig) recordLoginFailure(key string, policy auth.LockoutPolicy) {
+	failureParams := database.RecordLoginFailureParams{Key: key, ResetAfterSeconds: int32(policy.ResetAfter.Seconds())}
+	failures, err := a.dbQueries.RecordLoginFailure(context.Background(), failureParams)
+	if err != nil {
+		log.Printf("could not record login failure: %v", err)
+		return
+	}
+
+	delay := policy.Delay(int(failures))
+	if delay <= 0 {
+		return
+	}
+	if policy.LockedOut(int(failures)) {
+		log.Printf("locking %s after %d failed logins", key, failures)
+	}
+
+	lockParams := database.LockLoginThrottleParams{Key: key, LockSeconds: int32(math.Ceil(delay.Seconds()))}
+	err = a.dbQueries.LockLoginThrottle(context.Background(), lockParams)
+	if err != nil {
+		log.Printf("could not lock login throttle: %v", err)
+	}
+}
+
+// handleClearLockout lets an operator unlock an account or an address
+// before the lockout runs out.
+func (a *apiConfig) handleClearLockout(w http.ResponseWriter, r *http.Request) {
+	var err error
+	email := r.URL.Query().Get("email")
+	ip := r.URL.Query().Get("ip")
+	if email == "" && ip == "" {
+		respondWithError(w, http.StatusBadRequest, "email or ip is required")
+		return
+	}
+
+	if email != "" {
+		err = a.dbQueries.ClearLoginThrottle(context.Background(), accountThrottleKey(email))
+	}
+	if err == nil && ip != "" {
+		err = a.dbQueries.ClearLoginThrottle(context.Background(), ipThrottleKey(ip))
+	}
+	if err != nil {
+		log.Printf("could not clear lockout: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not clear lockout")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	mfaTokenDuration  = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+type mfaChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type secondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (a *apiConfig) totpEnabled(userID uuid.UUID) (bool, error) {
+	totp, err := a.dbQueries.GetTOTP(context.Background(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.EnabledAt.Valid, nil
+}
+
+// checkTOTP validates code and burns its time step so it cannot be replayed.
+func (a *apiConfig) checkTOTP(totp database.UserTotp, code string) (bool, error) {
+	secret, err := auth.DecryptSecret(totp.Secret, a.totpKey)
+	if err != nil {
+		return false, err
+	}
+
+	counter, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	counterParams := database.UseTOTPCounterParams{UserID: totp.UserID, LastUsedCounter: counter}
+	used, err := a.dbQueries.UseTOTPCounter(context.Background(), counterParams)
+	if err != nil {
+		return false, err
+	}
+	return used > 0, nil
+}
+
+func (a *apiConfig) checkSecondFactor(totp database.UserTotp, factor secondFactor) (bool, error) {
+	if factor.RecoveryCode == "" {
+		return a.checkTOTP(totp, factor.Code)
+	}
+
+	codeParams := database.UseRecoveryCodeParams{
+		UserID:   totp.UserID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(factor.RecoveryCode), a.tokenHashKey),
+	}
+	used, err := a.dbQueries.UseRecoveryCode(context.Background(), codeParams)
+	if err != nil {
+		return false, err
+	}
+	return used > 0, nil
+}
+
+// handleEnrollTOTP creates a new pending secret and recovery codes. The
+// secret only protects the account once a code from it is confirmed.
+func (a *apiConfig) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "token invalid")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		log.Printf("could not generate TOTP secret: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not enroll")
+		return
+	}
+
+	sealed, err := auth.EncryptSecret(secret, a.totpKey)
+	if err != nil {
+		log.Printf("could not encrypt TOTP secret: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not enroll")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Printf("could not generate recovery codes: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not enroll")
+		return
+	}
+
+	err = a.storeTOTPEnrollment(userID, sealed, codes)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		log.Printf("could not store TOTP enrollment: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not enroll")
+		return
+	}
+
+	response := struct {
+		Secret          string   `json:"secret"`
+		ProvisioningURI string   `json:"provisioning_uri"`
+		RecoveryCodes   []string `json:"recovery_codes"`
+	}{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI("Chirpy", user.Email, secret),
+		RecoveryCodes:   codes,
+	}
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (a *apiConfig) storeTOTPEnrollment(userID uuid.UUID, sealedSecret string, codes []string) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	qtx := a.dbQueries.WithTx(tx)
+
+	totpParams := database.UpsertTOTPParams{UserID: userID, Secret: sealedSecret}
+	_, err = qtx.UpsertTOTP(context.Background(), totpParams)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.DeleteRecoveryCodes(context.Background(), userID)
+	if err != nil {
+		return err
+	}
+	for _, code := range codes {
+		codeParams := database.CreateRecoveryCodeParams{UserID: userID, CodeHash: auth.HashToken(code, a.tokenHashKey)}
+		err = qtx.CreateRecoveryCode(context.Background(), codeParams)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (a *apiConfig) handleConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	totp, err := a.dbQueries.GetTOTP(context.Background(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "no pending enrollment")
+		return
+	}
+	if totp.EnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	valid, err := a.checkTOTP(totp, body.Code)
+	if err != nil {
+		log.Printf("could not check TOTP code: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not confirm enrollment")
+		return
+	}
+	if !valid {
+		respondWithError(w, http.StatusBadRequest, "invalid code")
+		return
+	}
+
+	_, err = a.dbQueries.EnableTOTP(context.Background(), userID)
+	if err != nil {
+		log.Printf("could not enable TOTP: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not confirm enrollment")
+		return
+	}
+	a.auditSelf(r, auditTOTPEnable, outcomeSuccess, userID, "")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDisableTOTP turns two-factor authentication off. An enabled factor
+// can only be removed with a current code or a recovery code.
+func (a *apiConfig) handleDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	var body secondFactor
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	totp, err := a.dbQueries.GetTOTP(context.Background(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "two-factor authentication is not enabled")
+		return
+	}
+
+	if totp.EnabledAt.Valid {
+		ok, err := a.checkSecondFactor(totp, body)
+		if err != nil {
+			log.Printf("could not check second factor: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "could not disable two-factor authentication")
+			return
+		}
+		if !ok {
+			a.auditSelf(r, auditTOTPDisable, outcomeFailure, userID, "invalid code")
+			respondWithError(w, http.StatusBadRequest, "invalid code")
+			return
+		}
+		if body.RecoveryCode != "" {
+			a.auditSelf(r, auditRecoveryCode, outcomeSuccess, userID, "disabling two-factor authentication")
+		}
+	}
+
+	err = a.dbQueries.DeleteTOTP(context.Background(), userID)
+	if err == nil {
+		err = a.dbQueries.DeleteRecoveryCodes(context.Background(), userID)
+	}
+	if err != nil {
+		log.Printf("could not disable TOTP: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not disable two-factor authentication")
+		return
+	}
+	a.auditSelf(r, auditTOTPDisable, outcomeSuccess, userID, "")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLoginMFA finishes a login that handleLogin answered with an MFA
+// challenge.
+func (a *apiConfig) handleLoginMFA(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		MFAToken string `json:"mfa_token"`
+		secondFactor
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	challenge, err := auth.ValidateMFAToken(a.mfaTokenKey, body.MFAToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid MFA token")
+		return
+	}
+	userID := challenge.UserID
+
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid MFA token")
+		return
+	}
+
+	// codes are guessed just like passwords, so they share the account lockout
+	accountKey := accountThrottleKey(user.Email)
+	if !a.checkLoginThrottle(w, accountKey, a.accountLockout) {
+		a.auditSelf(r, auditLoginMFA, outcomeFailure, user.ID, "throttled")
+		return
+	}
+
+	totp, err := a.dbQueries.GetTOTP(context.Background(), userID)
+	if err != nil || !totp.EnabledAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "invalid MFA token")
+		return
+	}
+
+	ok, err := a.checkSecondFactor(totp, body.secondFactor)
+	if err != nil {
+		log.Printf("could not check second factor: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not log in")
+		return
+	}
+	if !ok {
+		a.recordLoginFailure(accountKey, a.accountLockout)
+		a.auditSelf(r, auditLoginMFA, outcomeFailure, user.ID, "invalid code")
+		respondWithError(w, http.StatusUnauthorized, "invalid code")
+		return
+	}
+	if body.RecoveryCode != "" {
+		a.auditSelf(r, auditRecoveryCode, outcomeSuccess, user.ID, "logging in")
+	}
+
+	a.respondWithLogin(w, r, user, challenge.ExpiresIn)
+}
@@ -0,0 +1,685 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// oauthAccessTokenDuration is how long access tokens issued to third-party
+// clients are valid. They are refreshed with their refresh token.
+const oauthAccessTokenDuration = time.Hour
+
+type oauthClientEntry struct {
+	Id           uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	Secret       string    `json:"client_secret,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newOAuthClientEntry(client database.OauthClient) oauthClientEntry {
+	return oauthClientEntry{
+		Id:           client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.SecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// handleCreateOAuthClient registers a third-party application. Confidential
+// clients get a secret that is only ever shown in this response; public
+// clients such as mobile apps have none and must rely on PKCE.
+func (a *apiConfig) handleCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+
+	var body struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	if body.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if len(body.RedirectURIs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one redirect uri is required")
+		return
+	}
+	for _, redirectURI := range body.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid redirect uri %q", redirectURI))
+			return
+		}
+	}
+
+	scopes, err := auth.ValidateScopes(body.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one scope is required")
+		return
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if body.Confidential {
+		secret, err = auth.MakeRandomToken()
+		if err != nil {
+			log.Printf("could not create client secret: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "could not register client")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret, a.tokenHashKey), Valid: true}
+	}
+
+	clientParams := database.CreateOAuthClientParams{
+		OwnerID:      caller.UserID,
+		Name:         body.Name,
+		RedirectUris: body.RedirectURIs,
+		Scopes:       scopes,
+		SecretHash:   secretHash,
+	}
+	client, err := a.dbQueries.CreateOAuthClient(context.Background(), clientParams)
+	if err != nil {
+		log.Printf("could not insert oauth client: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not register client")
+		return
+	}
+
+	response := newOAuthClientEntry(client)
+	response.Secret = secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (a *apiConfig) handleGetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+
+	clients, err := a.dbQueries.ListOAuthClients(context.Background(), caller.UserID)
+	if err != nil {
+		log.Printf("could not list oauth clients: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list clients")
+		return
+	}
+
+	entries := make([]oauthClientEntry, 0, len(clients))
+	for _, client := range clients {
+		entries = append(entries, newOAuthClientEntry(client))
+	}
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+// handleDeleteOAuthClient removes a client. Its codes and refresh tokens go
+// with it, which logs the application out for every user.
+func (a *apiConfig) handleDeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+
+	clientID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "client not found")
+		return
+	}
+
+	deleteParams := database.DeleteOAuthClientParams{ID: clientID, OwnerID: caller.UserID}
+	rows, err := a.dbQueries.DeleteOAuthClient(context.Background(), deleteParams)
+	if err != nil {
+		log.Printf("could not delete oauth client: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not delete client")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "client not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validRedirectURI accepts absolute https URIs without a fragment. Plain
+// http is only allowed on the loopback interface, for native apps.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
+
+// oauthError is an error response as described in RFC 6749 section 5.2.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, errorCode, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, oauthError{Code: errorCode, Description: description})
+}
+
+type authorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// authorization is a checked authorizeRequest.
+type authorization struct {
+	client      database.OauthClient
+	redirectURI string
+	scopes      []string
+}
+
+// checkAuthorizeRequest validates req. When the client or redirect uri is
+// wrong the error must be shown to the user, so redirectable is false;
+// other errors are sent back to the client through the redirect uri.
+func (a *apiConfig) checkAuthorizeRequest(req authorizeRequest) (authz authorization, redirectable bool, err *oauthError) {
+	clientID, parseErr := uuid.Parse(req.ClientID)
+	if parseErr != nil {
+		return authorization{}, false, &oauthError{Code: "invalid_request", Description: "unknown client_id"}
+	}
+	client, dbErr := a.dbQueries.GetOAuthClient(context.Background(), clientID)
+	if dbErr != nil {
+		return authorization{}, false, &oauthError{Code: "invalid_request", Description: "unknown client_id"}
+	}
+
+	// the redirect uri may only be left out when there is no doubt about it
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return authorization{}, false, &oauthError{Code: "invalid_request", Description: "redirect_uri is not registered for this client"}
+	}
+
+	authz = authorization{client: client, redirectURI: redirectURI}
+	if req.ResponseType != "code" {
+		return authz, true, &oauthError{Code: "unsupported_response_type", Description: "only the code response type is supported"}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return authz, true, &oauthError{Code: "invalid_request", Description: "a PKCE code_challenge with method S256 is required"}
+	}
+
+	scopes, err2 := grantedScopes(client.Scopes, req.Scope)
+	if err2 != nil {
+		return authz, true, &oauthError{Code: "invalid_scope", Description: err2.Error()}
+	}
+	authz.scopes = scopes
+	return authz, true, nil
+}
+
+// grantedScopes resolves the space separated scope parameter against the
+// scopes allowed. Leaving it out asks for all of them.
+func grantedScopes(allowed []string, scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+
+	scopes, err := auth.ValidateScopes(requested)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scopes {
+		if !slices.Contains(allowed, s) {
+			return nil, fmt.Errorf("scope %q is not allowed for this client", s)
+		}
+	}
+	return scopes, nil
+}
+
+// redirectWith appends params to the query of redirectURI.
+func redirectWith(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// handleAuthorizeConsent checks an authorization request and describes it
+// so the frontend can ask the signed in user for consent.
+func (a *apiConfig) handleAuthorizeConsent(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := authorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	authz, _, oauthErr := a.checkAuthorizeRequest(req)
+	if oauthErr != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		ClientID    uuid.UUID `json:"client_id"`
+		ClientName  string    `json:"client_name"`
+		RedirectURI string    `json:"redirect_uri"`
+		Scopes      []string  `json:"scopes"`
+	}{
+		ClientID:    authz.client.ID,
+		ClientName:  authz.client.Name,
+		RedirectURI: authz.redirectURI,
+		Scopes:      authz.scopes,
+	})
+}
+
+// handleAuthorize records the user's decision on an authorization request.
+// The response holds the uri the frontend sends the browser to, carrying
+// either an authorization code or an access_denied error.
+func (a *apiConfig) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+
+	var body struct {
+		authorizeRequest
+		Approved bool `json:"approved"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	authz, redirectable, oauthErr := a.checkAuthorizeRequest(body.authorizeRequest)
+	if oauthErr != nil && !redirectable {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		return
+	}
+	if oauthErr == nil && !body.Approved {
+		oauthErr = &oauthError{Code: "access_denied", Description: "the user denied the request"}
+	}
+	if oauthErr != nil {
+		params := url.Values{"error": {oauthErr.Code}, "error_description": {oauthErr.Description}, "state": {body.State}}
+		respondWithJSON(w, http.StatusOK, map[string]string{"redirect_uri": redirectWith(authz.redirectURI, params)})
+		return
+	}
+
+	code, err := auth.MakeRandomToken()
+	if err != nil {
+		log.Printf("could not create authorization code: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not authorize client")
+		return
+	}
+
+	codeParams := database.CreateAuthorizationCodeParams{
+		CodePrefix:    auth.TokenPrefix(code),
+		CodeHash:      auth.HashToken(code, a.tokenHashKey),
+		ClientID:      authz.client.ID,
+		UserID:        caller.UserID,
+		RedirectUri:   authz.redirectURI,
+		Scopes:        authz.scopes,
+		CodeChallenge: body.CodeChallenge,
+	}
+	_, err = a.dbQueries.CreateAuthorizationCode(context.Background(), codeParams)
+	if err != nil {
+		log.Printf("could not insert authorization code: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not authorize client")
+		return
+	}
+	a.auditSelf(r, auditOAuthGrant, outcomeSuccess, caller.UserID,
+		"client="+authz.client.ID.String()+" scopes="+strings.Join(authz.scopes, " "))
+
+	params := url.Values{"code": {code}, "state": {body.State}}
+	respondWithJSON(w, http.StatusOK, map[string]string{"redirect_uri": redirectWith(authz.redirectURI, params)})
+}
+
+// authenticateClient identifies the client calling the token, introspection
+// or revocation endpoint, from HTTP Basic credentials or the request body.
+// Public clients only send their client_id.
+func (a *apiConfig) authenticateClient(r *http.Request) (database.OauthClient, bool) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes both before encoding them
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return database.OauthClient{}, false
+	}
+	client, err := a.dbQueries.GetOAuthClient(context.Background(), clientID)
+	if err != nil {
+		return database.OauthClient{}, false
+	}
+
+	if client.SecretHash.Valid {
+		return client, auth.CheckTokenHash(secret, client.SecretHash.String, a.tokenHashKey)
+	}
+	return client, secret == ""
+}
+
+// parseClientRequest parses the form of a request to one of the endpoints
+// clients call directly and authenticates the client. It answers the
+// request and returns false on failure.
+func (a *apiConfig) parseClientRequest(w http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "could not parse form")
+		return database.OauthClient{}, false
+	}
+
+	client, ok := a.authenticateClient(r)
+	if !ok {
+		if _, _, basic := r.BasicAuth(); basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		}
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return database.OauthClient{}, false
+	}
+	return client, true
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// handleOAuthToken is the token endpoint of RFC 6749. It supports the
+// authorization_code grant with PKCE and the refresh_token grant.
+func (a *apiConfig) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := a.parseClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		a.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		a.refreshClientToken(w, r, client)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+func (a *apiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	code, err := a.findAuthorizationCode(r.PostForm.Get("code"))
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+		return
+	}
+	if code.ClientID != client.ID {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+		return
+	}
+
+	// a code presented twice was intercepted, so whatever was issued for it
+	// is revoked as well
+	if code.UsedAt.Valid {
+		a.revokeReusedCode(code)
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+		return
+	}
+
+	if r.PostForm.Get("redirect_uri") != code.RedirectUri {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match")
+		return
+	}
+	if !auth.CheckPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid code_verifier")
+		return
+	}
+
+	rows, err := a.dbQueries.UseAuthorizationCode(context.Background(), code.ID)
+	if err != nil {
+		log.Printf("could not use authorization code: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if rows == 0 {
+		a.revokeReusedCode(code)
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+		return
+	}
+
+	a.respondWithClientTokens(w, r, client, code.UserID, code.FamilyID, code.Scopes)
+}
+
+func (a *apiConfig) refreshClientToken(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	stored, err := a.findRefreshToken(r.PostForm.Get("refresh_token"))
+	if err != nil || stored.ClientID.UUID != client.ID || !stored.ClientID.Valid {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	// a client may narrow its scopes when refreshing, never widen them
+	scopes, err := grantedScopes(stored.Scopes, r.PostForm.Get("scope"))
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+
+	err = a.useRefreshToken(stored)
+	if errors.Is(err, errRefreshTokenInvalid) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+	if err != nil {
+		log.Printf("could not consume refresh token: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	a.respondWithClientTokens(w, r, client, stored.UserID, stored.FamilyID, scopes)
+}
+
+// respondWithClientTokens issues an access token limited to scopes and a
+// refresh token in the given family.
+func (a *apiConfig) respondWithClientTokens(w http.ResponseWriter, r *http.Request, client database.OauthClient, userID, familyID uuid.UUID, scopes []string) {
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil || user.DeletedAt.Valid {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "the user no longer exists")
+		return
+	}
+
+	accessToken, err := a.jwtKeys.MakeClientJWT(user.ID, client.ID.String(), scopes, oauthAccessTokenDuration)
+	if err != nil {
+		log.Printf("could not create client access token: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	refreshToken, err := a.registerClientRefreshToken(user.ID, familyID, client.ID, scopes, r)
+	if err != nil {
+		log.Printf("could not create client refresh token: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	respondWithJSON(w, http.StatusOK, oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	})
+}
+
+// registerClientRefreshToken is registerRefreshToken for tokens held by a
+// third-party client, which stay bound to that client and its scopes.
+func (a *apiConfig) registerClientRefreshToken(userID, familyID, clientID uuid.UUID, scopes []string, r *http.Request) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", fmt.Errorf("could not create refresh token: %v", err)
+	}
+
+	createRefreshTokenParams := database.CreateRefreshTokenParams{
+		TokenPrefix: auth.TokenPrefix(refreshToken),
+		TokenHash:   auth.HashToken(refreshToken, a.tokenHashKey),
+		UserID:      userID,
+		FamilyID:    familyID,
+		UserAgent:   r.UserAgent(),
+		IpAddress:   a.clientIP(r),
+		ClientID:    uuid.NullUUID{UUID: clientID, Valid: true},
+		Scopes:      scopes,
+	}
+	_, err = a.dbQueries.CreateRefreshToken(context.Background(), createRefreshTokenParams)
+	if err != nil {
+		return "", fmt.Errorf("could not insert refresh token: %v", err)
+	}
+
+	return refreshToken, nil
+}
+
+// findAuthorizationCode looks a code up by its prefix and checks its hash.
+func (a *apiConfig) findAuthorizationCode(code string) (database.OauthAuthorizationCode, error) {
+	candidates, err := a.dbQueries.GetAuthorizationCodesByPrefix(context.Background(), auth.TokenPrefix(code))
+	if err != nil {
+		return database.OauthAuthorizationCode{}, err
+	}
+	for _, candidate := range candidates {
+		if auth.CheckTokenHash(code, candidate.CodeHash, a.tokenHashKey) {
+			return candidate, nil
+		}
+	}
+	return database.OauthAuthorizationCode{}, sql.ErrNoRows
+}
+
+func (a *apiConfig) revokeReusedCode(code database.OauthAuthorizationCode) {
+	log.Printf("authorization code reuse detected for client %v, revoking token family %v", code.ClientID, code.FamilyID)
+	err := a.dbQueries.RevokeTokenFamily(context.Background(), code.FamilyID)
+	if err != nil {
+		log.Printf("could not revoke token family %v: %v", code.FamilyID, err)
+	}
+}
+
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// handleIntrospect implements RFC 7662. Clients may only introspect tokens
+// that were issued to them; anything else is reported as inactive.
+func (a *apiConfig) handleIntrospect(w http.ResponseWriter, r *http.Request) {
+	client, ok := a.parseClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	response := introspectionResponse{}
+
+	claims, err := a.jwtKeys.ValidateAccessToken(token)
+	if err == nil && claims.ClientID == client.ID.String() {
+		response = introspectionResponse{
+			Active:    true,
+			Scope:     strings.Join(claims.Scopes, " "),
+			ClientID:  claims.ClientID,
+			Subject:   claims.UserID.String(),
+			TokenType: "Bearer",
+			IssuedAt:  claims.IssuedAt.Unix(),
+			ExpiresAt: claims.ExpiresAt.Unix(),
+		}
+	} else if stored, err := a.findRefreshToken(token); err == nil && stored.ClientID.Valid && stored.ClientID.UUID == client.ID {
+		if !stored.RevokedAt.Valid && !stored.ConsumedAt.Valid && stored.ExpiresAt.After(time.Now()) {
+			response = introspectionResponse{
+				Active:    true,
+				Scope:     strings.Join(stored.Scopes, " "),
+				ClientID:  client.ID.String(),
+				Subject:   stored.UserID.String(),
+				TokenType: "refresh_token",
+				IssuedAt:  stored.CreatedAt.Unix(),
+				ExpiresAt: stored.ExpiresAt.Unix(),
+			}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleOAuthRevoke implements RFC 7009. Revoking a refresh token revokes
+// its whole family. Access tokens are short lived and cannot be revoked.
+// As the RFC asks, unknown tokens are not an error.
+func (a *apiConfig) handleOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	client, ok := a.parseClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	stored, err := a.findRefreshToken(r.PostForm.Get("token"))
+	if err == nil && stored.ClientID.Valid && stored.ClientID.UUID == client.ID {
+		err = a.dbQueries.RevokeTokenFamily(context.Background(), stored.FamilyID)
+		if err != nil {
+			log.Printf("could not revoke token family %v: %v", stored.FamilyID, err)
+			respondWithOAuthError(w, http.StatusServiceUnavailable, "server_error", "")
+			return
+		}
+		// the client revoked the grant, not the user
+		a.audit(r, auditEntry{
+			Event:   auditOAuthRevoke,
+			Outcome: outcomeSuccess,
+			UserID:  stored.UserID,
+			Details: "client=" + client.ID.String(),
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/ChernakovEgor/chirpy/internal/oidc"
+	"github.com/google/uuid"
+)
+
+type oidcIdentityEntry struct {
+	Id          uuid.UUID `json:"id"`
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+func newOIDCIdentityEntry(identity database.OidcIdentity) oidcIdentityEntry {
+	return oidcIdentityEntry{
+		Id:          identity.ID,
+		Issuer:      identity.Issuer,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		CreatedAt:   identity.CreatedAt,
+		LastLoginAt: identity.LastLoginAt,
+	}
+}
+
+// newOIDCProviders configures the providers named in OIDC_PROVIDERS. A
+// provider called "google" is read from OIDC_GOOGLE_ISSUER,
+// OIDC_GOOGLE_CLIENT_ID and OIDC_GOOGLE_CLIENT_SECRET, and must accept
+// {publicURL}/api/oidc/google/callback as its redirect URL.
+func newOIDCProviders(publicURL string) (map[string]*oidc.Provider, error) {
+	providers := make(map[string]*oidc.Provider)
+	for _, name := range splitList(os.Getenv("OIDC_PROVIDERS")) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		config := oidc.Config{
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  fmt.Sprintf("%s/api/oidc/%s/callback", publicURL, name),
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			config.Scopes = strings.Fields(scopes)
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID must be set", prefix, prefix)
+		}
+		providers[name] = oidc.NewProvider(config, nil)
+	}
+	return providers, nil
+}
+
+// handleOIDCLogin sends the browser to the provider to sign in.
+func (a *apiConfig) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, ok := a.startOIDCFlow(w, r.PathValue("provider"), uuid.NullUUID{})
+	if !ok {
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// handleOIDCLink starts a flow that adds the provider account to the
+// caller's account instead of logging in.
+func (a *apiConfig) handleOIDCLink(w http.ResponseWriter, r *http.Request) {
+	linkUserID := uuid.NullUUID{UUID: principalFrom(r).UserID, Valid: true}
+	authURL, ok := a.startOIDCFlow(w, r.PathValue("provider"), linkUserID)
+	if !ok {
+		return
+	}
+
+	response := struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}{AuthorizationURL: authURL}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (a *apiConfig) startOIDCFlow(w http.ResponseWriter, providerName string, linkUserID uuid.NullUUID) (string, bool) {
+	provider, ok := a.oidcProviders[providerName]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "unknown provider")
+		return "", false
+	}
+
+	authURL, state, err := a.oidcAuthorizationURL(provider, providerName, linkUserID)
+	if err != nil {
+		log.Printf("could not start %s login: %v", providerName, err)
+		respondWithError(w, http.StatusBadGateway, "could not reach identity provider")
+		return "", false
+	}
+	a.setOIDCStateCookie(w, state, int(oidcStateLifetime.Seconds()))
+	return authURL, true
+}
+
+// The state of a flow is also kept in a cookie, so that the callback only
+// completes in the browser that started the flow. Otherwise an attacker
+// could have a victim's browser finish the attacker's own login.
+const (
+	oidcStateCookie = "oidc_state"
+	// oidcStateLifetime matches how long CreateOIDCLoginState keeps a state.
+	oidcStateLifetime = 10 * time.Minute
+)
+
+// setOIDCStateCookie sets the state cookie for maxAge seconds, or clears
+// it when maxAge is negative.
+func (a *apiConfig) setOIDCStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/oidc/",
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(a.publicURL, "https://"),
+		HttpOnly: true,
+		// Lax still sends the cookie on the provider's top-level redirect
+		// back to the callback.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// oidcAuthorizationURL remembers the state, nonce and PKCE verifier of a
+// new flow for the callback and returns where to send the browser along
+// with the state.
+func (a *apiConfig) oidcAuthorizationURL(provider *oidc.Provider, providerName string, linkUserID uuid.NullUUID) (string, string, error) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", "", err
+	}
+
+	err = a.dbQueries.DeleteExpiredOIDCLoginStates(context.Background())
+	if err != nil {
+		log.Printf("could not delete expired login states: %v", err)
+	}
+	err = a.dbQueries.CreateOIDCLoginState(context.Background(), database.CreateOIDCLoginStateParams{
+		State:        state,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("could not store login state: %v", err)
+	}
+
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, challenge)
+	return authURL, state, err
+}
+
+// handleOIDCCallback is where the provider sends the browser back. The
+// code is exchanged for an ID token, whose (issuer, subject) pair names the
+// Chirpy account to log in to.
+func (a *apiConfig) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	providerName := r.PathValue("provider")
+	provider, ok := a.oidcProviders[providerName]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "unknown provider")
+		return
+	}
+
+	query := r.URL.Query()
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		respondWithError(w, http.StatusBadRequest, "login was not started in this browser")
+		return
+	}
+	a.setOIDCStateCookie(w, "", -1)
+
+	stateParams := database.ConsumeOIDCLoginStateParams{State: query.Get("state"), Provider: providerName}
+	state, err := a.dbQueries.ConsumeOIDCLoginState(context.Background(), stateParams)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired state")
+		return
+	}
+	if providerError := query.Get("error"); providerError != "" {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("identity provider refused the login: %s", providerError))
+		return
+	}
+
+	idToken, err := provider.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("could not complete %s login: %v", providerName, err)
+		respondWithError(w, http.StatusUnauthorized, "could not verify identity")
+		return
+	}
+
+	if state.LinkUserID.Valid {
+		a.linkOIDCIdentity(w, state.LinkUserID.UUID, idToken)
+		return
+	}
+
+	user, err := a.userForOIDCIdentity(idToken)
+	if errors.Is(err, errEmailTaken) {
+		respondWithError(w, http.StatusConflict, "an account with this email address already exists, log in and link this provider to it")
+		return
+	}
+	if err != nil {
+		log.Printf("could not resolve %s identity: %v", providerName, err)
+		respondWithError(w, http.StatusInternalServerError, "could not log in")
+		return
+	}
+
+	a.completeLogin(w, r, user, 0)
+}
+
+var errEmailTaken = errors.New("email address belongs to another account")
+
+// userForOIDCIdentity finds the account linked to idToken. Unknown
+// identities are linked to the account with the same email address when
+// both sides have verified it, and get a new account otherwise.
+func (a *apiConfig) userForOIDCIdentity(idToken oidc.IDToken) (database.User, error) {
+	identityParams := database.GetOIDCIdentityParams{Issuer: idToken.Issuer, Subject: idToken.Subject}
+	identity, err := a.dbQueries.GetOIDCIdentity(context.Background(), identityParams)
+	if err == nil {
+		touchParams := database.TouchOIDCIdentityParams{ID: identity.ID, Email: idToken.Email}
+		err = a.dbQueries.TouchOIDCIdentity(context.Background(), touchParams)
+		if err != nil {
+			log.Printf("could not update identity %v: %v", identity.ID, err)
+		}
+		return a.dbQueries.GetUserByID(context.Background(), identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if idToken.Email == "" {
+		return database.User{}, errors.New("provider did not share an email address")
+	}
+
+	existing, err := a.dbQueries.GetUserByEmail(context.Background(), idToken.Email)
+	if err == nil {
+		if !idToken.EmailVerified || !existing.EmailVerifiedAt.Valid {
+			return database.User{}, errEmailTaken
+		}
+		_, err = a.dbQueries.CreateOIDCIdentity(context.Background(), database.CreateOIDCIdentityParams{
+			UserID:  existing.ID,
+			Issuer:  idToken.Issuer,
+			Subject: idToken.Subject,
+			Email:   idToken.Email,
+		})
+		return existing, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	return a.createOIDCUser(idToken)
+}
+
+// createOIDCUser signs up a user who only logs in through a provider. Their
+// empty password hash never matches, until they set one with a password
+// reset.
+func (a *apiConfig) createOIDCUser(idToken oidc.IDToken) (database.User, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return database.User{}, fmt.Errorf("could not begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	qtx := a.dbQueries.WithTx(tx)
+
+	userParams := database.CreateExternalUserParams{Email: idToken.Email}
+	if idToken.EmailVerified {
+		userParams.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	user, err := qtx.CreateExternalUser(context.Background(), userParams)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	_, err = qtx.CreateOIDCIdentity(context.Background(), database.CreateOIDCIdentityParams{
+		UserID:  user.ID,
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   idToken.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+func (a *apiConfig) linkOIDCIdentity(w http.ResponseWriter, userID uuid.UUID, idToken oidc.IDToken) {
+	identityParams := database.GetOIDCIdentityParams{Issuer: idToken.Issuer, Subject: idToken.Subject}
+	identity, err := a.dbQueries.GetOIDCIdentity(context.Background(), identityParams)
+	if err == nil {
+		if identity.UserID != userID {
+			respondWithError(w, http.StatusConflict, "this identity is linked to another account")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, newOIDCIdentityEntry(identity))
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("could not look up identity: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not link identity")
+		return
+	}
+
+	identity, err = a.dbQueries.CreateOIDCIdentity(context.Background(), database.CreateOIDCIdentityParams{
+		UserID:  userID,
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   idToken.Email,
+	})
+	if err != nil {
+		log.Printf("could not link identity: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not link identity")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newOIDCIdentityEntry(identity))
+}
+
+func (a *apiConfig) handleGetOIDCIdentities(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	identities, err := a.dbQueries.ListOIDCIdentities(context.Background(), userID)
+	if err != nil {
+		log.Printf("could not list identities: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list identities")
+		return
+	}
+
+	response := []oidcIdentityEntry{}
+	for _, identity := range identities {
+		response = append(response, newOIDCIdentityEntry(identity))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleDeleteOIDCIdentity unlinks a provider. The last identity of an
+// account without a password cannot be removed, as that would lock the
+// user out.
+func (a *apiConfig) handleDeleteOIDCIdentity(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	identityID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "identity not found")
+		return
+	}
+
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid jwt token")
+		return
+	}
+	identities, err := a.dbQueries.ListOIDCIdentities(context.Background(), userID)
+	if err != nil {
+		log.Printf("could not list identities: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not unlink identity")
+		return
+	}
+	if user.HashedPassword == "" && len(identities) == 1 && identities[0].ID == identityID {
+		respondWithError(w, http.StatusConflict, "set a password before unlinking your only identity provider")
+		return
+	}
+
+	deleteParams := database.DeleteOIDCIdentityParams{ID: identityID, UserID: userID}
+	deleted, err := a.dbQueries.DeleteOIDCIdentity(context.Background(), deleteParams)
+	if err != nil {
+		log.Printf("could not unlink identity: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not unlink identity")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "identity not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/ChernakovEgor/chirpy/internal/mail"
+)
+
+// handlePasswordResetRequest always answers 202 so the response does not
+// reveal whether an account exists. The lookup and the email happen in the
+// background to keep the response time the same either way.
+func (a *apiConfig) handlePasswordResetRequest(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	go func() {
+		err := a.sendPasswordResetEmail(body.Email)
+		if err != nil {
+			log.Printf("could not send password reset email: %v", err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *apiConfig) sendPasswordResetEmail(email string) error {
+	user, err := a.dbQueries.GetUserByEmail(context.Background(), email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get user: %v", err)
+	}
+
+	token, err := auth.MakeRandomToken()
+	if err != nil {
+		return fmt.Errorf("could not create reset token: %v", err)
+	}
+
+	tokenParams := database.CreatePasswordResetTokenParams{
+		UserID:      user.ID,
+		TokenPrefix: auth.TokenPrefix(token),
+		TokenHash:   auth.HashToken(token, a.tokenHashKey),
+	}
+	_, err = a.dbQueries.CreatePasswordResetToken(context.Background(), tokenParams)
+	if err != nil {
+		return fmt.Errorf("could not insert reset token: %v", err)
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", a.publicURL, url.QueryEscape(token))
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body:    fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\nChoose a new password within 30 minutes here:\n\n%s\n\nIf it was not you, ignore this email.\n", link),
+	}
+	return a.mailer.Send(msg)
+}
+
+// handlePasswordResetConfirm sets a new password and logs the account out of
+// every session.
+func (a *apiConfig) handlePasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	stored, err := a.findPasswordResetToken(body.Token)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired token")
+		return
+	}
+
+	user, err := a.dbQueries.GetUserByID(context.Background(), stored.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired token")
+		return
+	}
+	if !a.checkPasswordPolicy(w, body.Password, user.Email) {
+		return
+	}
+
+	hashedPassword, err := a.passwordHasher.Hash(body.Password)
+	if err != nil {
+		log.Printf("could not hash password: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not reset password")
+		return
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		log.Printf("could not begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not reset password")
+		return
+	}
+	defer tx.Rollback()
+	qtx := a.dbQueries.WithTx(tx)
+
+	used, err := qtx.UsePasswordResetToken(context.Background(), stored.ID)
+	if err != nil {
+		log.Printf("could not use reset token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not reset password")
+		return
+	}
+	if used == 0 {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired token")
+		return
+	}
+
+	updateParams := database.UpdatePasswordParams{ID: stored.UserID, HashedPassword: hashedPassword}
+	_, err = qtx.UpdatePassword(context.Background(), updateParams)
+	if err == nil {
+		err = qtx.InvalidatePasswordResetTokens(context.Background(), stored.UserID)
+	}
+	if err == nil {
+		err = qtx.RevokeAllUserTokens(context.Background(), stored.UserID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("could not reset password: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not reset password")
+		return
+	}
+	a.auditSelf(r, auditPasswordReset, outcomeSuccess, stored.UserID, "")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *apiConfig) findPasswordResetToken(token string) (database.PasswordResetToken, error) {
+	candidates, err := a.dbQueries.GetPasswordResetTokensByPrefix(context.Background(), auth.TokenPrefix(token))
+	if err != nil {
+		return database.PasswordResetToken{}, err
+	}
+
+	for _, candidate := range candidates {
+		if auth.CheckTokenHash(token, candidate.TokenHash, a.tokenHashKey) {
+			return candidate, nil
+		}
+	}
+	return database.PasswordResetToken{}, sql.ErrNoRows
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/ChernakovEgor/chirpy/internal/auth"
@@ -33,7 +32,9 @@ func (a *apiConfig) handlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	err = decoder.Decode(&polkaRequest)
 	if err != nil {
-		log.Fatalf("could not decode body: %v", err)
+		a.audit(r, auditEntry{Event: auditChirpyRed, Outcome: outcomeFailure, Details: "polka: malformed body"})
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
 	}
 
 	eventType := polkaRequest.Event
@@ -45,9 +46,11 @@ func (a *apiConfig) handlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
 	// userID, err := uuid.Parse(polkaRequest.Data.UserID)
 	_, err = a.dbQueries.UpgradeToRed(context.Background(), polkaRequest.Data.UserID)
 	if err != nil {
+		a.audit(r, auditEntry{Event: auditChirpyRed, Outcome: outcomeFailure, UserID: polkaRequest.Data.UserID, Details: "polka: user not found"})
 		respondWithError(w, http.StatusNotFound, "user not found")
 		return
 	}
+	a.audit(r, auditEntry{Event: auditChirpyRed, Outcome: outcomeSuccess, UserID: polkaRequest.Data.UserID, Details: "polka"})
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
 )
 
+// handleRefresh exchanges a refresh token for a new access token and a new
+// refresh token from the same family. Each refresh token can be used once;
+// presenting a consumed token means it leaked, so the whole family is revoked.
 func (a *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -15,20 +21,98 @@ func (a *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := a.dbQueries.GetUserByToken(context.Background(), refreshToken)
+	// tokens of third-party clients are refreshed at the token endpoint,
+	// where they stay limited to their scopes
+	stored, err := a.findRefreshToken(refreshToken)
+	if err != nil || stored.ClientID.Valid {
+		respondWithError(w, http.StatusUnauthorized, "token does not exist")
+		return
+	}
+
+	err = a.useRefreshToken(stored)
+	if errors.Is(err, errRefreshTokenInvalid) {
+		a.auditSelf(r, auditTokenRefresh, outcomeFailure, stored.UserID, "token revoked or already used")
+		respondWithError(w, http.StatusUnauthorized, "token expired")
+		return
+	}
+	if err != nil {
+		log.Printf("could not consume refresh token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not refresh token")
+		return
+	}
+
+	// the role is read again so that role changes reach the next access token
+	user, err := a.dbQueries.GetUserByID(context.Background(), stored.UserID)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "token expired")
 		return
 	}
 
-	jwtToken, err := a.registerJWT(userID, 3600)
+	jwtToken, err := a.registerJWT(user, 3600)
+	if err != nil {
+		log.Printf("could not create JWT token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not refresh token")
+		return
+	}
+
+	newRefreshToken, err := a.registerRefreshToken(stored.UserID, stored.FamilyID, r)
 	if err != nil {
-		log.Fatalf("could not create JWT token: %v", err)
+		log.Printf("could not create refresh token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not refresh token")
+		return
 	}
 
 	response := struct {
-		Token string `json:"token"`
-	}{Token: jwtToken}
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}{Token: jwtToken, RefreshToken: newRefreshToken}
 
+	a.auditSelf(r, auditTokenRefresh, outcomeSuccess, user.ID, "")
 	respondWithJSON(w, http.StatusOK, response)
 }
+
+var errRefreshTokenInvalid = errors.New("refresh token is revoked or used")
+
+// useRefreshToken consumes stored so that it cannot be presented again. A
+// token that was already used has leaked, so its whole family is revoked.
+func (a *apiConfig) useRefreshToken(stored database.RefreshToken) error {
+	if stored.RevokedAt.Valid {
+		return errRefreshTokenInvalid
+	}
+	if stored.ConsumedAt.Valid {
+		a.revokeReusedToken(stored)
+		return errRefreshTokenInvalid
+	}
+
+	_, err := a.dbQueries.ConsumeRefreshToken(context.Background(), stored.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// another request consumed the token between the lookup and here
+		a.revokeReusedToken(stored)
+		return errRefreshTokenInvalid
+	}
+	return err
+}
+
+// findRefreshToken looks a token up by its plaintext prefix and compares the
+// keyed hashes of the candidates.
+func (a *apiConfig) findRefreshToken(token string) (database.RefreshToken, error) {
+	candidates, err := a.dbQueries.GetRefreshTokensByPrefix(context.Background(), auth.TokenPrefix(token))
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	for _, candidate := range candidates {
+		if auth.CheckTokenHash(token, candidate.TokenHash, a.tokenHashKey) {
+			return candidate, nil
+		}
+	}
+	return database.RefreshToken{}, sql.ErrNoRows
+}
+
+func (a *apiConfig) revokeReusedToken(stored database.RefreshToken) {
+	log.Printf("refresh token reuse detected for user %v, revoking token family %v", stored.UserID, stored.FamilyID)
+	err := a.dbQueries.RevokeTokenFamily(context.Background(), stored.FamilyID)
+	if err != nil {
+		log.Printf("could not revoke token family %v: %v", stored.FamilyID, err)
+	}
+}
@@ -14,11 +14,18 @@ func (a *apiConfig) handleRevoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = a.dbQueries.RevokeToken(context.Background(), refreshToken)
+	stored, err := a.findRefreshToken(refreshToken)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "token expired")
 		return
 	}
 
+	_, err = a.dbQueries.RevokeToken(context.Background(), stored.ID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "token expired")
+		return
+	}
+	a.auditSelf(r, auditTokenRevoke, outcomeSuccess, stored.UserID, "")
+
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (a *apiConfig) handleSetUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+	if !auth.ValidRole(body.Role) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("role must be one of %s", strings.Join(auth.Roles, ", ")))
+		return
+	}
+
+	roleParams := database.SetUserRoleParams{ID: userID, Role: body.Role}
+	user, err := a.dbQueries.SetUserRole(context.Background(), roleParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		log.Printf("could not set role: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not set role")
+		return
+	}
+	a.audit(r, auditEntry{
+		Event:   auditRoleChange,
+		Outcome: outcomeSuccess,
+		UserID:  user.ID,
+		ActorID: principalFrom(r).UserID,
+		Details: "role=" + user.Role,
+	})
+
+	response := userResponse{
+		Id:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		IsVerified:  user.EmailVerifiedAt.Valid,
+		Role:        user.Role,
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// promoteAdmins makes the existing accounts in emails admins, so that the
+// first admin can be created without touching the database. Accounts are
+// only promoted once their address is verified.
+func (a *apiConfig) promoteAdmins(emails []string) {
+	for _, email := range emails {
+		roleParams := database.SetUserRoleByEmailParams{Email: email, Role: auth.RoleAdmin}
+		promoted, err := a.dbQueries.SetUserRoleByEmail(context.Background(), roleParams)
+		if err != nil {
+			log.Printf("could not promote %s to admin: %v", email, err)
+			continue
+		}
+		if promoted == 0 {
+			log.Printf("could not promote %s to admin: no verified, active account with that email", email)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/ChernakovEgor/chirpy/internal/search"
+	"github.com/google/uuid"
+)
+
+const maxSearchQueryLength = 256
+
+type searchResult struct {
+	chirpEntry
+	Rank float32 `json:"rank"`
+	// Snippet is the HTML-escaped body with matches wrapped in <mark>.
+	Snippet string `json:"snippet"`
+}
+
+type searchPage struct {
+	Results    []searchResult `json:"results"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// handleSearchChirps runs a full-text search over chirp bodies. See
+// search.ParseQuery for what q may contain. Results are sorted by
+// relevance unless sort=recent.
+func (a *apiConfig) handleSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := query.Get("q")
+	if len(q) > maxSearchQueryLength {
+		respondWithError(w, http.StatusBadRequest, "q is too long")
+		return
+	}
+	tsquery, err := search.ParseQuery(q)
+	if errors.Is(err, search.ErrEmptyQuery) {
+		respondWithError(w, http.StatusBadRequest, "q must contain at least one word to search for")
+		return
+	}
+
+	limit, ok := parsePageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	sortBy := query.Get("sort")
+	if sortBy != "" && sortBy != "relevance" && sortBy != "recent" {
+		respondWithError(w, http.StatusBadRequest, "sort must be relevance or recent")
+		return
+	}
+	byRecency := sortBy == "recent"
+
+	var rows []database.SearchChirpsByRelevanceRow
+	if byRecency {
+		rows, ok = a.searchByRecency(w, tsquery, query.Get("cursor"), limit)
+	} else {
+		rows, ok = a.searchByRelevance(w, tsquery, query.Get("cursor"), limit)
+	}
+	if !ok {
+		return
+	}
+
+	page := searchPage{Results: make([]searchResult, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		next := encodeSearchCursor(last.Rank, last.Chirp.CreatedAt, last.Chirp.ID)
+		if byRecency {
+			next = encodeChirpCursor(last.Chirp.CreatedAt, last.Chirp.ID)
+		}
+		page.NextCursor = &next
+	}
+	for _, row := range rows {
+		page.Results = append(page.Results, searchResult{
+			chirpEntry: newChirpEntry(row.Chirp),
+			Rank:       row.Rank,
+			Snippet:    row.Snippet,
+		})
+	}
+	refs := make([]*chirpEntry, 0, len(page.Results))
+	for i := range page.Results {
+		refs = append(refs, &page.Results[i].chirpEntry)
+	}
+	a.fillChirpEntries(r, refs...)
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// searchByRelevance fetches one row more than limit so the caller can tell
+// whether there is another page.
+func (a *apiConfig) searchByRelevance(w http.ResponseWriter, tsquery, cursor string, limit int) ([]database.SearchChirpsByRelevanceRow, bool) {
+	params := database.SearchChirpsByRelevanceParams{Query: tsquery, MaxResults: int32(limit + 1)}
+	if cursor != "" {
+		rank, createdAt, id, err := decodeSearchCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor")
+			return nil, false
+		}
+		params.AfterRank = sql.NullFloat64{Float64: float64(rank), Valid: true}
+		params.AfterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := a.dbQueries.SearchChirpsByRelevance(context.Background(), params)
+	if err != nil {
+		log.Printf("could not search chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not search chirps")
+		return nil, false
+	}
+	return rows, true
+}
+
+func (a *apiConfig) searchByRecency(w http.ResponseWriter, tsquery, cursor string, limit int) ([]database.SearchChirpsByRelevanceRow, bool) {
+	params := database.SearchChirpsByRecencyParams{Query: tsquery, MaxResults: int32(limit + 1)}
+	if cursor != "" {
+		createdAt, id, err := decodeChirpCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor")
+			return nil, false
+		}
+		params.AfterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := a.dbQueries.SearchChirpsByRecency(context.Background(), params)
+	if err != nil {
+		log.Printf("could not search chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not search chirps")
+		return nil, false
+	}
+
+	results := make([]database.SearchChirpsByRelevanceRow, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, database.SearchChirpsByRelevanceRow(row))
+	}
+	return results, true
+}
+
+// encodeSearchCursor keys relevance pages on the rank as well. The rank is
+// formatted so that it parses back to exactly the same float32, which the
+// database compares against its own.
+func encodeSearchCursor(rank float32, createdAt time.Time, id uuid.UUID) string {
+	return encodeCursor(strconv.FormatFloat(float64(rank), 'g', -1, 32), createdAt.UTC().Format(time.RFC3339Nano), id.String())
+}
+
+func decodeSearchCursor(cursor string) (float32, time.Time, uuid.UUID, error) {
+	parts, err := decodeCursor(cursor, 3)
+	if err != nil {
+		return 0, time.Time{}, uuid.Nil, err
+	}
+	rank, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil {
+		return 0, time.Time{}, uuid.Nil, err
+	}
+	createdAt, id, err := parseChirpKey(parts[1], parts[2])
+	if err != nil {
+		return 0, time.Time{}, uuid.Nil, err
+	}
+	return float32(rank), createdAt, id, nil
+}
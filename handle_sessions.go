@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// A session is a refresh token family: it starts at login and lives on
+// through every rotation until it is revoked or expires.
+type sessionEntry struct {
+	Id         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+}
+
+func (a *apiConfig) handleGetSessions(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	sessions, err := a.dbQueries.ListActiveSessions(context.Background(), userID)
+	if err != nil {
+		log.Printf("could not list sessions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list sessions")
+		return
+	}
+
+	response := []sessionEntry{}
+	for _, session := range sessions {
+		response = append(response, sessionEntry{
+			Id:         session.FamilyID,
+			CreatedAt:  session.StartedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (a *apiConfig) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	revokeParams := database.RevokeUserSessionParams{FamilyID: sessionID, UserID: userID}
+	revoked, err := a.dbQueries.RevokeUserSession(context.Background(), revokeParams)
+	if err != nil {
+		log.Printf("could not revoke session: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not revoke session")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	a.auditSelf(r, auditSessionRevoke, outcomeSuccess, userID, "session="+sessionID.String())
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *apiConfig) handleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+	userID := caller.UserID
+
+	err := a.dbQueries.RevokeAllUserTokens(context.Background(), userID)
+	if err != nil {
+		log.Printf("could not revoke sessions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not revoke sessions")
+		return
+	}
+	a.auditSelf(r, auditLogoutAll, outcomeSuccess, userID, "")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientIP returns the address of the caller. X-Forwarded-For is only
+// believed when chirpy runs behind a proxy that sets it.
+func (a *apiConfig) clientIP(r *http.Request) string {
+	if a.trustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// handleRechirp reshares a chirp as the caller. Rechirping a rechirp
+// reshares the original, and rechirping a chirp again returns the
+// existing rechirp.
+func (a *apiConfig) handleRechirp(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "incorrect chirp id")
+		return
+	}
+
+	if !a.checkCanPost(w, userID) {
+		return
+	}
+
+	original, err := a.getSharedChirp(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+
+	status := http.StatusCreated
+	rechirpParams := database.CreateRechirpParams{
+		UserID:    userID,
+		RechirpOf: uuid.NullUUID{UUID: original.ID, Valid: true},
+	}
+	rechirp, err := a.dbQueries.CreateRechirp(context.Background(), rechirpParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		// already rechirped
+		status = http.StatusOK
+		rechirp, err = a.dbQueries.GetRechirp(context.Background(), database.GetRechirpParams(rechirpParams))
+	}
+	if err != nil {
+		log.Printf("could not rechirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not rechirp")
+		return
+	}
+
+	entry := newChirpEntry(rechirp)
+	a.fillChirpEntries(r, &entry)
+	respondWithJSON(w, status, entry)
+}
+
+// handleUndoRechirp deletes the caller's rechirp of a chirp, if there is
+// one. The chirp may be given by its own id or by that of the rechirp.
+func (a *apiConfig) handleUndoRechirp(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "incorrect chirp id")
+		return
+	}
+
+	chirp, err := a.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err == nil && chirp.RechirpOf.Valid {
+		chirpID = chirp.RechirpOf.UUID
+	}
+
+	deleteParams := database.DeleteRechirpParams{
+		UserID:    userID,
+		RechirpOf: uuid.NullUUID{UUID: chirpID, Valid: true},
+	}
+	_, err = a.dbQueries.DeleteRechirp(context.Background(), deleteParams)
+	if err != nil {
+		log.Printf("could not undo rechirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not undo rechirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getSharedChirp looks up the chirp that replying to, quoting or
+// rechirping chirpID refers to. That is the chirp itself unless it is a
+// rechirp, in which case it is the original.
+func (a *apiConfig) getSharedChirp(chirpID uuid.UUID) (database.Chirp, error) {
+	chirp, err := a.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err != nil || !chirp.RechirpOf.Valid {
+		return chirp, err
+	}
+	return a.dbQueries.GetChirpByID(context.Background(), chirp.RechirpOf.UUID)
+}
+
+// embedSharedChirps sets Rechirped and Quoted on entries with a single
+// query, and returns the entries it embedded.
+func (a *apiConfig) embedSharedChirps(entries ...*chirpEntry) []*chirpEntry {
+	var ids []uuid.UUID
+	for _, entry := range entries {
+		if entry.RechirpOf != nil {
+			ids = append(ids, *entry.RechirpOf)
+		}
+		if entry.QuoteOf != nil {
+			ids = append(ids, *entry.QuoteOf)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	chirps, err := a.dbQueries.ListChirpsByIDs(context.Background(), ids)
+	if err != nil {
+		log.Printf("could not look up shared chirps: %v", err)
+		return nil
+	}
+	shared := make(map[uuid.UUID]database.Chirp, len(chirps))
+	for _, chirp := range chirps {
+		shared[chirp.ID] = chirp
+	}
+
+	var embedded []*chirpEntry
+	embed := func(id *uuid.UUID) *chirpEntry {
+		if id == nil {
+			return nil
+		}
+		chirp, ok := shared[*id]
+		if !ok {
+			return nil
+		}
+		entry := newChirpEntry(chirp)
+		embedded = append(embedded, &entry)
+		return &entry
+	}
+	for _, entry := range entries {
+		entry.Rechirped = embed(entry.RechirpOf)
+		entry.Quoted = embed(entry.QuoteOf)
+	}
+	return embedded
+}
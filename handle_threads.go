@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type threadReply struct {
+	chirpEntry
+	// Depth is 1 for direct replies to the chirp the thread is about.
+	Depth int32 `json:"depth"`
+}
+
+type threadView struct {
+	Chirp      chirpEntry    `json:"chirp"`
+	Ancestors  []chirpEntry  `json:"ancestors"`
+	Replies    []threadReply `json:"replies"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+// handleGetChirpThread shows a chirp in its conversation: the chain of
+// chirps it replies to, oldest first, and a page of the replies below it
+// in tree order. Every reply directly follows the chirp it answers. The
+// ancestors only come with the first page. Deleted chirps drop out of the
+// thread without taking the replies below them along.
+func (a *apiConfig) handleGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "incorrect chirp id")
+		return
+	}
+
+	limit, ok := parsePageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.ListChirpDescendantsParams{ChirpID: chirpID, MaxResults: int32(limit + 1)}
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		afterPath, err := decodeThreadCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		params.AfterPath = afterPath
+	}
+
+	chirp, err := a.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	view := threadView{Chirp: newChirpEntry(chirp), Ancestors: []chirpEntry{}}
+
+	if cursor == "" && len(chirp.ThreadPath) > 1 {
+		ancestors, err := a.dbQueries.ListChirpAncestors(context.Background(), chirpID)
+		if err != nil {
+			log.Printf("could not list chirp ancestors: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "could not load thread")
+			return
+		}
+		for _, ancestor := range ancestors {
+			view.Ancestors = append(view.Ancestors, newChirpEntry(ancestor))
+		}
+	}
+
+	replies, err := a.dbQueries.ListChirpDescendants(context.Background(), params)
+	if err != nil {
+		log.Printf("could not list chirp replies: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not load thread")
+		return
+	}
+
+	view.Replies = make([]threadReply, 0, len(replies))
+	if len(replies) > limit {
+		replies = replies[:limit]
+		next := encodeThreadCursor(replies[limit-1].Chirp.ThreadPath)
+		view.NextCursor = &next
+	}
+	for _, reply := range replies {
+		view.Replies = append(view.Replies, threadReply{
+			chirpEntry: newChirpEntry(reply.Chirp),
+			Depth:      reply.Depth,
+		})
+	}
+
+	refs := append(entryRefs(view.Ancestors), &view.Chirp)
+	for i := range view.Replies {
+		refs = append(refs, &view.Replies[i].chirpEntry)
+	}
+	a.fillChirpEntries(r, refs...)
+
+	respondWithJSON(w, http.StatusOK, view)
+}
+
+// encodeThreadCursor keys thread pages on the thread_path of the last
+// reply, so a page can follow it even after that reply is deleted.
+func encodeThreadCursor(path []string) string {
+	return encodeCursor(strings.Join(path, "/"))
+}
+
+func decodeThreadCursor(cursor string) ([]string, error) {
+	parts, err := decodeCursor(cursor, 1)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.Split(parts[0], "/")
+	for _, key := range path {
+		if key == "" {
+			return nil, errors.New("malformed cursor")
+		}
+	}
+	return path, nil
+}
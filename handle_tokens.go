@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxPersonalAccessTokenDays = 365
+
+type personalAccessTokenEntry struct {
+	Id         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+func newPersonalAccessTokenEntry(token database.PersonalAccessToken) personalAccessTokenEntry {
+	entry := personalAccessTokenEntry{
+		Id:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+	}
+	if token.LastUsedAt.Valid {
+		entry.LastUsedAt = &token.LastUsedAt.Time
+	}
+	if token.ExpiresAt.Valid {
+		entry.ExpiresAt = &token.ExpiresAt.Time
+	}
+	return entry
+}
+
+// handleCreatePersonalAccessToken mints a long-lived token for scripts. The
+// token itself is only ever shown in this response.
+func (a *apiConfig) handleCreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+
+	var body struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	if body.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if body.ExpiresInDays < 0 || body.ExpiresInDays > maxPersonalAccessTokenDays {
+		respondWithError(w, http.StatusBadRequest, "expires_in_days must be between 0 and 365")
+		return
+	}
+
+	scopes, err := auth.ValidateScopes(body.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one scope is required")
+		return
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		log.Printf("could not create personal access token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not create token")
+		return
+	}
+
+	var expiresAt sql.NullTime
+	if body.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, body.ExpiresInDays), Valid: true}
+	}
+
+	tokenParams := database.CreatePersonalAccessTokenParams{
+		UserID:      caller.UserID,
+		Name:        body.Name,
+		TokenPrefix: auth.PersonalAccessTokenPrefix(token),
+		TokenHash:   auth.HashToken(token, a.tokenHashKey),
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	}
+	stored, err := a.dbQueries.CreatePersonalAccessToken(context.Background(), tokenParams)
+	if err != nil {
+		log.Printf("could not insert personal access token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not create token")
+		return
+	}
+
+	a.auditSelf(r, auditPATCreate, outcomeSuccess, caller.UserID, "token="+stored.ID.String()+" scopes="+strings.Join(scopes, " "))
+
+	response := newPersonalAccessTokenEntry(stored)
+	response.Token = token
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (a *apiConfig) handleGetPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+
+	tokens, err := a.dbQueries.ListPersonalAccessTokens(context.Background(), caller.UserID)
+	if err != nil {
+		log.Printf("could not list personal access tokens: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not list tokens")
+		return
+	}
+
+	response := []personalAccessTokenEntry{}
+	for _, token := range tokens {
+		response = append(response, newPersonalAccessTokenEntry(token))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (a *apiConfig) handleDeletePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+
+	tokenID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "token not found")
+		return
+	}
+
+	revokeParams := database.RevokePersonalAccessTokenParams{ID: tokenID, UserID: caller.UserID}
+	revoked, err := a.dbQueries.RevokePersonalAccessToken(context.Background(), revokeParams)
+	if err != nil {
+		log.Printf("could not revoke personal access token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not revoke token")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "token not found")
+		return
+	}
+	a.auditSelf(r, auditPATDelete, outcomeSuccess, caller.UserID, "token="+tokenID.String())
+
+	w.WriteHeader(http.StatusNoContent)
+}
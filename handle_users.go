@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +12,9 @@ import (
 
 	"github.com/ChernakovEgor/chirpy/internal/auth"
 	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/ChernakovEgor/chirpy/internal/mail"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type userResponse struct {
@@ -21,6 +25,8 @@ type userResponse struct {
 	JWTtoken     string    `json:"token,omitempty"`
 	RefreshToken string    `json:"refresh_token,omitempty"`
 	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	IsVerified   bool      `json:"is_email_verified"`
+	Role         string    `json:"role"`
 }
 
 func (a *apiConfig) handleUsers(w http.ResponseWriter, r *http.Request) {
@@ -33,19 +39,40 @@ func (a *apiConfig) handleUsers(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&userRequest)
 	if err != nil {
-		log.Fatalf("could not decode body: %v", err)
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
 	}
 
 	email := userRequest.Email
-	hashedPassword, err := auth.HashPassword(userRequest.Password)
+	if !a.checkPasswordPolicy(w, userRequest.Password, email) {
+		return
+	}
+
+	hashedPassword, err := a.passwordHasher.Hash(userRequest.Password)
 	if err != nil {
-		log.Fatalf("could not hash password: %v", err)
+		log.Printf("could not hash password: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not create user")
+		return
 	}
 
 	userParams := database.CreateUserParams{Email: email, HashedPassword: hashedPassword}
 	user, err := a.dbQueries.CreateUser(context.Background(), userParams)
+	// accounts scheduled for deletion and accounts made through OIDC hold on
+	// to their address too
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondWithError(w, http.StatusConflict, "email address is already in use")
+		return
+	}
 	if err != nil {
-		log.Fatalf("could not create user: %v", err)
+		log.Printf("could not create user: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not create user")
+		return
+	}
+
+	err = a.sendVerificationEmail(user.ID, user.Email)
+	if err != nil {
+		log.Printf("could not send verification email to user %v: %v", user.ID, err)
 	}
 
 	responseStruct := userResponse{
@@ -53,6 +80,7 @@ func (a *apiConfig) handleUsers(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 		Email:     user.Email,
+		Role:      auth.RoleUser,
 	}
 
 	respondWithJSON(w, http.StatusCreated, responseStruct)
@@ -68,38 +96,139 @@ func (a *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&loginRequest)
 	if err != nil {
-		log.Fatalf("could not decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	ipKey := ipThrottleKey(a.clientIP(r))
+	accountKey := accountThrottleKey(loginRequest.Email)
+	if !a.checkLoginThrottle(w, ipKey, a.ipLockout) || !a.checkLoginThrottle(w, accountKey, a.accountLockout) {
+		// attributed to the account, if there is one, so its owner sees
+		// the attempts that were turned away
+		user, _ := a.dbQueries.GetUserByEmail(context.Background(), loginRequest.Email)
+		a.auditSelf(r, auditLogin, outcomeFailure, user.ID, "throttled, email="+loginRequest.Email)
+		return
 	}
 
 	user, err := a.dbQueries.GetUserByEmail(context.Background(), loginRequest.Email)
-	if err := auth.CheckPasswordHash(loginRequest.Password, user.HashedPassword); err != nil {
+	hashedPassword := user.HashedPassword
+	if errors.Is(err, sql.ErrNoRows) {
+		hashedPassword = a.dummyPasswordHash
+	} else if err != nil {
+		log.Printf("could not get user: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not log in")
+		return
+	}
+	if err := auth.CheckPasswordHash(loginRequest.Password, hashedPassword); err != nil || user.ID == uuid.Nil {
+		a.recordLoginFailure(ipKey, a.ipLockout)
+		a.recordLoginFailure(accountKey, a.accountLockout)
+		a.auditSelf(r, auditLogin, outcomeFailure, user.ID, "email="+loginRequest.Email)
 		respondWithError(w, http.StatusUnauthorized, "")
 		return
 	}
 
-	jwtToken, err := a.registerJWT(user.ID, loginRequest.ExpiresIn)
+	if a.passwordHasher.NeedsRehash(user.HashedPassword) {
+		a.rehashPassword(user, loginRequest.Password)
+	}
+
+	a.completeLogin(w, r, user, loginRequest.ExpiresIn)
+}
+
+// rehashPassword replaces a stored hash made with an outdated algorithm or
+// parameters. Failing to do so is not a reason to refuse the login.
+func (a *apiConfig) rehashPassword(user database.User, password string) {
+	hashedPassword, err := a.passwordHasher.Hash(password)
+	if err != nil {
+		log.Printf("could not rehash password: %v", err)
+		return
+	}
+
+	passwordParams := database.UpdatePasswordParams{ID: user.ID, HashedPassword: hashedPassword}
+	_, err = a.dbQueries.UpdatePassword(context.Background(), passwordParams)
+	if err != nil {
+		log.Printf("could not store rehashed password: %v", err)
+	}
+}
+
+// completeLogin finishes the first step of a login: users with two-factor
+// authentication get an MFA challenge, everyone else is logged in.
+func (a *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User, expiresIn int) {
+	mfaRequired, err := a.totpEnabled(user.ID)
+	if err != nil {
+		log.Printf("could not check two-factor authentication: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not log in")
+		return
+	}
+
+	if mfaRequired {
+		// the challenge carries expiresIn through to handleLoginMFA
+		challenge := auth.MFAChallenge{UserID: user.ID, ExpiresIn: expiresIn}
+		mfaToken, err := auth.MakeMFAToken(a.mfaTokenKey, challenge, mfaTokenDuration)
+		if err != nil {
+			log.Printf("could not create MFA token: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "could not log in")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, mfaChallenge{MFARequired: true, MFAToken: mfaToken})
+		return
+	}
+
+	a.respondWithLogin(w, r, user, expiresIn)
+}
+
+// respondWithLogin issues an access token and starts a new session for a
+// user who has fully authenticated.
+func (a *apiConfig) respondWithLogin(w http.ResponseWriter, r *http.Request, user database.User, expiresIn int) {
+	if user.DeletedAt.Valid {
+		restored, err := a.restoreAccount(user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "")
+			return
+		}
+		if err != nil {
+			log.Printf("could not restore account: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "could not log in")
+			return
+		}
+		a.auditSelf(r, auditAccountRestore, outcomeSuccess, user.ID, "")
+		user = restored
+	}
+
+	err := a.dbQueries.ClearLoginThrottle(context.Background(), accountThrottleKey(user.Email))
 	if err != nil {
-		log.Fatalf("could not create JWT token: %v", err)
+		log.Printf("could not clear login throttle: %v", err)
 	}
+	a.auditSelf(r, auditLogin, outcomeSuccess, user.ID, "")
 
-	refreshToken, err := a.registerRefreshToken(user.ID)
+	jwtToken, err := a.registerJWT(user, expiresIn)
 	if err != nil {
-		log.Fatalf("could not create refresh token: %v", err)
+		log.Printf("could not create JWT token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not log in")
+		return
+	}
+
+	refreshToken, err := a.registerRefreshToken(user.ID, uuid.New(), r)
+	if err != nil {
+		log.Printf("could not create refresh token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not log in")
+		return
 	}
 
 	loggedUser := userResponse{
-		user.ID,
-		user.CreatedAt,
-		user.UpdatedAt,
-		user.Email,
-		jwtToken,
-		refreshToken,
-		user.IsChirpyRed,
+		Id:           user.ID,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+		Email:        user.Email,
+		JWTtoken:     jwtToken,
+		RefreshToken: refreshToken,
+		IsChirpyRed:  user.IsChirpyRed,
+		IsVerified:   user.EmailVerifiedAt.Valid,
+		Role:         user.Role,
 	}
 	respondWithJSON(w, http.StatusOK, loggedUser)
 }
 
-func (a *apiConfig) registerJWT(userID uuid.UUID, expiresIn int) (string, error) {
+func (a *apiConfig) registerJWT(user database.User, expiresIn int) (string, error) {
 	var tokenDuration time.Duration
 	if expiresIn == 0 || expiresIn > 3600 {
 		tokenDuration = time.Hour
@@ -107,7 +236,7 @@ func (a *apiConfig) registerJWT(userID uuid.UUID, expiresIn int) (string, error)
 		tokenDuration = time.Duration(expiresIn) * time.Second
 	}
 
-	token, err := auth.MakeJWT(userID, a.jwtSecret, tokenDuration)
+	token, err := a.jwtKeys.MakeJWT(user.ID, user.Role, tokenDuration)
 	if err != nil {
 		return "", fmt.Errorf("could not create token: %v", err)
 	}
@@ -115,13 +244,23 @@ func (a *apiConfig) registerJWT(userID uuid.UUID, expiresIn int) (string, error)
 	return token, nil
 }
 
-func (a *apiConfig) registerRefreshToken(userID uuid.UUID) (string, error) {
+// registerRefreshToken issues a refresh token in the given family. Logging in
+// starts a new family; refreshing continues the family of the consumed token.
+// The client of r is recorded so users can recognise their sessions.
+func (a *apiConfig) registerRefreshToken(userID, familyID uuid.UUID, r *http.Request) (string, error) {
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
 		return "", fmt.Errorf("could not create refresh token: %v", err)
 	}
 
-	createRefreshTokenParams := database.CreateRefreshTokenParams{Token: refreshToken, UserID: userID}
+	createRefreshTokenParams := database.CreateRefreshTokenParams{
+		TokenPrefix: auth.TokenPrefix(refreshToken),
+		TokenHash:   auth.HashToken(refreshToken, a.tokenHashKey),
+		UserID:      userID,
+		FamilyID:    familyID,
+		UserAgent:   r.UserAgent(),
+		IpAddress:   a.clientIP(r),
+	}
 	_, err = a.dbQueries.CreateRefreshToken(context.Background(), createRefreshTokenParams)
 	if err != nil {
 		return "", fmt.Errorf("could not insert refresh token: %v", err)
@@ -130,46 +269,80 @@ func (a *apiConfig) registerRefreshToken(userID uuid.UUID) (string, error) {
 	return refreshToken, nil
 }
 
-func (a *apiConfig) handleUpdateCredentials(w http.ResponseWriter, r *http.Request) {
-	jwtToken, err := auth.GetBearerToken(r.Header)
+// handleChangePassword replaces the caller's password. The current
+// password is required so that a stolen access token is not enough to take
+// over the account.
+func (a *apiConfig) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "jwt token expired")
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
 		return
 	}
 
-	userID, err := auth.ValidateJWT(jwtToken, a.jwtSecret)
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "invalid jwt token")
 		return
 	}
 
-	var body struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+	if !a.checkCurrentPassword(w, user, body.CurrentPassword) {
+		a.auditSelf(r, auditPasswordChange, outcomeFailure, user.ID, "current password rejected")
+		return
 	}
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&body)
+	if !a.checkPasswordPolicy(w, body.NewPassword, user.Email) {
+		return
+	}
+
+	hashedPassword, err := a.passwordHasher.Hash(body.NewPassword)
 	if err != nil {
-		log.Fatalf("could not decode body: %v", err)
+		log.Printf("could not hash password: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not change password")
+		return
 	}
 
-	hashedPass, err := auth.HashPassword(body.Password)
+	passwordParams := database.UpdatePasswordParams{ID: userID, HashedPassword: hashedPassword}
+	_, err = a.dbQueries.UpdatePassword(context.Background(), passwordParams)
 	if err != nil {
-		log.Fatalf("could not hash password: %v", err)
+		log.Printf("could not update password: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not change password")
+		return
 	}
+	a.auditSelf(r, auditPasswordChange, outcomeSuccess, user.ID, "")
 
-	updateUserParams := database.UpdateEmailAndPasswordParams{ID: userID, Email: body.Email, HashedPassword: hashedPass}
-	user, err := a.dbQueries.UpdateEmailAndPassword(context.Background(), updateUserParams)
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy password was changed",
+		Body:    "The password of your Chirpy account was just changed.\n\nIf this wasn't you, reset your password right away.\n",
+	}
+	err = a.mailer.Send(msg)
 	if err != nil {
-		log.Fatalf("could not update user: %v", err)
+		log.Printf("could not send password change notice to user %v: %v", user.ID, err)
 	}
 
-	userRes := userResponse{
-		Id:        userID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email:     user.Email,
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkCurrentPassword answers the request and returns false unless
+// password is user's password. Wrong guesses count towards the account
+// lockout just like failed logins.
+func (a *apiConfig) checkCurrentPassword(w http.ResponseWriter, user database.User, password string) bool {
+	accountKey := accountThrottleKey(user.Email)
+	if !a.checkLoginThrottle(w, accountKey, a.accountLockout) {
+		return false
 	}
 
-	respondWithJSON(w, http.StatusOK, userRes)
+	err := auth.CheckPasswordHash(password, user.HashedPassword)
+	if err != nil {
+		a.recordLoginFailure(accountKey, a.accountLockout)
+		respondWithError(w, http.StatusUnauthorized, "incorrect password")
+		return false
+	}
+	return true
 }
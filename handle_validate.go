@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 )
 
+const maxChirpLength = 140
+
 func handleValidate(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	type validateRequest struct {
@@ -20,16 +23,26 @@ func handleValidate(w http.ResponseWriter, r *http.Request) {
 		log.Printf("could not decode request: %v", err)
 	}
 
-	if len(vRequest.Body) > 140 {
+	msg, err := cleanChirpBody(vRequest.Body)
+	if err != nil {
 		respondWithError(w, 400, "Chirp is too long")
 		return
-	} else {
-		msg := filterProfane(vRequest.Body)
-		filtered := struct {
-			CleanedBody string `json:"cleaned_body"`
-		}{CleanedBody: msg}
-		respondWithJSON(w, http.StatusOK, filtered)
 	}
+	filtered := struct {
+		CleanedBody string `json:"cleaned_body"`
+	}{CleanedBody: msg}
+	respondWithJSON(w, http.StatusOK, filtered)
+}
+
+var errChirpTooLong = errors.New("chirp is too long")
+
+// cleanChirpBody checks the length of a chirp and filters profanity out of
+// it, as every body is before it is stored.
+func cleanChirpBody(body string) (string, error) {
+	if len(body) > maxChirpLength {
+		return "", errChirpTooLong
+	}
+	return filterProfane(body), nil
 }
 
 func respondWithError(w http.ResponseWriter, code int, msg string) {
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/ChernakovEgor/chirpy/internal/mail"
+	"github.com/google/uuid"
+)
+
+// Actions unverified users can be barred from with UNVERIFIED_RESTRICTIONS.
+const (
+	restrictPostChirps = "post_chirps"
+)
+
+var knownRestrictions = []string{restrictPostChirps}
+
+// isRestricted reports whether user may not perform action until their email
+// address is verified.
+func (a *apiConfig) isRestricted(user database.User, action string) bool {
+	return a.unverifiedRestrictions[action] && !user.EmailVerifiedAt.Valid
+}
+
+func (a *apiConfig) sendVerificationEmail(userID uuid.UUID, email string) error {
+	token, err := auth.MakeRandomToken()
+	if err != nil {
+		return fmt.Errorf("could not create verification token: %v", err)
+	}
+
+	tokenParams := database.CreateEmailVerificationTokenParams{
+		UserID:      userID,
+		TokenPrefix: auth.TokenPrefix(token),
+		TokenHash:   auth.HashToken(token, a.tokenHashKey),
+	}
+	_, err = a.dbQueries.CreateEmailVerificationToken(context.Background(), tokenParams)
+	if err != nil {
+		return fmt.Errorf("could not insert verification token: %v", err)
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", a.publicURL, url.QueryEscape(token))
+	msg := mail.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email address",
+		Body:    fmt.Sprintf("Welcome to Chirpy!\n\nConfirm your email address by opening this link within 24 hours:\n\n%s\n", link),
+	}
+	return a.mailer.Send(msg)
+}
+
+func (a *apiConfig) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode body")
+		return
+	}
+
+	stored, err := a.findEmailVerificationToken(body.Token)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired token")
+		return
+	}
+
+	used, err := a.dbQueries.UseEmailVerificationToken(context.Background(), stored.ID)
+	if err != nil {
+		log.Printf("could not use verification token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not verify email")
+		return
+	}
+	if used == 0 {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired token")
+		return
+	}
+
+	_, err = a.dbQueries.MarkEmailVerified(context.Background(), stored.UserID)
+	if err != nil {
+		log.Printf("could not mark email verified: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not verify email")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *apiConfig) handleResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	user, err := a.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "email already verified")
+		return
+	}
+
+	err = a.sendVerificationEmail(user.ID, user.Email)
+	if err != nil {
+		log.Printf("could not send verification email: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "could not send verification email")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *apiConfig) findEmailVerificationToken(token string) (database.EmailVerificationToken, error) {
+	candidates, err := a.dbQueries.GetEmailVerificationTokensByPrefix(context.Background(), auth.TokenPrefix(token))
+	if err != nil {
+		return database.EmailVerificationToken{}, err
+	}
+
+	for _, candidate := range candidates {
+		if auth.CheckTokenHash(token, candidate.TokenHash, a.tokenHashKey) {
+			return candidate, nil
+		}
+	}
+	return database.EmailVerificationToken{}, sql.ErrNoRows
+}
@@ -1,80 +1,44 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), 10)
-	if err != nil {
-		return "", fmt.Errorf("could not hash password: %v", err)
-	}
-	return string(hashed), nil
-}
-
-func CheckPasswordHash(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-}
+const tokenPrefixLength = 12
 
+// MakeJWT signs an HS256 token with a shared secret. Services that verify
+// tokens without the secret should use a KeyRing with an asymmetric key.
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	issued := time.Now()
-	expires := issued.Add(expiresIn)
-	claims := jwt.RegisteredClaims{Issuer: "chirpy",
-		IssuedAt:  &jwt.NumericDate{issued},
-		ExpiresAt: &jwt.NumericDate{expires},
-		Subject:   string(userID.String())}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	res, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", err
-	}
-	return res, nil
+	return hmacRing(tokenSecret).MakeJWT(userID, RoleUser, expiresIn)
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	var claims struct {
-		jwt.RegisteredClaims
-	}
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("could not parse token: %v", err)
-	}
-
-	idString, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("could not get subject from token: %v", err)
-	}
-	issuer, err := token.Claims.GetIssuer()
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("could not get issuer from token: %v", err)
-	}
-	if issuer != "chirpy" {
-		return uuid.Nil, fmt.Errorf("invalid issuer: %v", issuer)
-	}
+	return hmacRing(tokenSecret).ValidateJWT(tokenString)
+}
 
-	userID, err := uuid.Parse(idString)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("could not parse uuid: %v", err)
-	}
-	return userID, nil
+func hmacRing(tokenSecret string) *KeyRing {
+	ring := NewKeyRing()
+	ring.Add(NewHMACKey("", []byte(tokenSecret)))
+	ring.SetActive("")
+	return ring
 }
 
+var ErrNoAuthHeader = errors.New("no header 'Authorization'")
+
 func GetBearerToken(headers http.Header) (string, error) {
 	value := headers.Get("Authorization")
 	if value == "" {
-		return "", fmt.Errorf("no header 'Authorization'")
+		return "", ErrNoAuthHeader
 	}
 
 	token := strings.TrimPrefix(value, "Bearer ")
@@ -82,6 +46,12 @@ func GetBearerToken(headers http.Header) (string, error) {
 }
 
 func MakeRefreshToken() (string, error) {
+	return MakeRandomToken()
+}
+
+// MakeRandomToken returns 32 random bytes as hex, for opaque single-use
+// tokens such as the ones sent by email.
+func MakeRandomToken() (string, error) {
 	data := make([]byte, 32)
 	_, err := rand.Read(data)
 	if err != nil {
@@ -92,6 +62,26 @@ func MakeRefreshToken() (string, error) {
 	return token, nil
 }
 
+// TokenPrefix returns the part of a token that is stored in plaintext so the
+// row can be found without knowing the key the rest is hashed with.
+func TokenPrefix(token string) string {
+	if len(token) < tokenPrefixLength {
+		return token
+	}
+	return token[:tokenPrefixLength]
+}
+
+// HashToken returns the keyed hash of a token that is stored in place of it.
+func HashToken(token string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func CheckTokenHash(token, hash string, key []byte) bool {
+	return hmac.Equal([]byte(HashToken(token, key)), []byte(hash))
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	value := headers.Get("Authorization")
 	if value == "" {
@@ -26,3 +26,72 @@ func TestValidateJWT(t *testing.T) {
 		t.Errorf("got %v want %v", got, want)
 	}
 }
+
+func TestCheckTokenHash(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("could not make token: %v", err)
+	}
+
+	key := []byte("key")
+	hash := HashToken(token, key)
+	if hash == token || TokenPrefix(token) != token[:12] {
+		t.Errorf("token stored in plaintext")
+	}
+	if !CheckTokenHash(token, hash, key) {
+		t.Errorf("token does not match its hash")
+	}
+	if CheckTokenHash(token, hash, []byte("other key")) {
+		t.Errorf("token matched a hash made with another key")
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	got, err := ValidateScopes([]string{ScopeChirpsWrite, ScopeChirpsRead, ScopeChirpsWrite})
+	if err != nil {
+		t.Fatalf("could not validate scopes: %v", err)
+	}
+	if len(got) != 2 || got[0] != ScopeChirpsRead || got[1] != ScopeChirpsWrite {
+		t.Errorf("got %v", got)
+	}
+
+	if _, err := ValidateScopes([]string{"admin"}); err == nil {
+		t.Errorf("unknown scope accepted")
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role, required string
+		want           bool
+	}{
+		{RoleAdmin, RoleModerator, true},
+		{RoleModerator, RoleModerator, true},
+		{RoleUser, RoleModerator, false},
+		{RoleModerator, RoleAdmin, false},
+		{"root", RoleUser, false},
+		{RoleAdmin, "root", false},
+	}
+
+	for _, tc := range tests {
+		if got := HasRole(tc.role, tc.required); got != tc.want {
+			t.Errorf("HasRole(%q, %q) = %v want %v", tc.role, tc.required, got, tc.want)
+		}
+	}
+}
+
+func TestCheckPKCE(t *testing.T) {
+	// example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !CheckPKCE(verifier, challenge) {
+		t.Errorf("RFC 7636 example did not verify")
+	}
+	if CheckPKCE(verifier[1:], challenge) {
+		t.Errorf("wrong verifier accepted")
+	}
+	if CheckPKCE("short", "short") {
+		t.Errorf("verifier below the minimum length accepted")
+	}
+}
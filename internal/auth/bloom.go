@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// BloomFilter is a fixed size set that can answer "definitely not present"
+// or "probably present". It keeps a corpus of millions of breached
+// passwords in memory without storing the passwords themselves.
+type BloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint64
+}
+
+// NewBloomFilter sizes a filter for n items at the given false positive
+// rate.
+func NewBloomFilter(n int, falsePositiveRate float64) *BloomFilter {
+	n = max(n, 1)
+	size := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	size = max(size, 64)
+	hashes := uint64(math.Round(float64(size) / float64(n) * math.Ln2))
+	return &BloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: max(hashes, 1),
+	}
+}
+
+// Add and Test take a SHA-1 digest as returned by BreachedPasswordKey, so
+// the filter can be filled from hash-only corpora.
+func (b *BloomFilter) Add(key [sha1.Size]byte) {
+	h1, h2 := splitKey(key)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *BloomFilter) Test(key [sha1.Size]byte) bool {
+	h1, h2 := splitKey(key)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func splitKey(key [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(key[:8]), binary.BigEndian.Uint64(key[8:16]) | 1
+}
+
+func BreachedPasswordKey(password string) [sha1.Size]byte {
+	return sha1.Sum([]byte(password))
+}
+
+// LoadBreachedPasswords builds a filter from a file in the Have I Been Pwned
+// download format: one upper case SHA-1 hex digest per line, optionally
+// followed by ":count". Lines with fewer than minCount occurrences are
+// skipped.
+func LoadBreachedPasswords(path string, minCount int) (*BloomFilter, error) {
+	lines, err := countLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached passwords: %v", err)
+	}
+	defer file.Close()
+
+	filter := NewBloomFilter(lines, 0.001)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		digest, countString, _ := strings.Cut(line, ":")
+		if countString != "" && minCount > 1 {
+			var count int
+			_, err := fmt.Sscanf(countString, "%d", &count)
+			if err == nil && count < minCount {
+				continue
+			}
+		}
+
+		var key [sha1.Size]byte
+		decoded, err := hex.DecodeString(digest)
+		if err != nil || len(decoded) != sha1.Size {
+			return nil, fmt.Errorf("line %d of %s is not a SHA-1 digest", lineNumber, path)
+		}
+		copy(key[:], decoded)
+		filter.Add(key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read breached passwords: %v", err)
+	}
+
+	return filter, nil
+}
+
+func countLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not open breached passwords: %v", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines, scanner.Err()
+}
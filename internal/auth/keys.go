@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+const minRSABits = 2048
+
+// SigningKey is a single key in a KeyRing. Keys loaded from a public key
+// only can verify tokens but never sign them.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{ID: id, Algorithm: AlgHS256, signKey: secret, verifyKey: secret}
+}
+
+func NewPrivateKey(id string, private crypto.PrivateKey) (*SigningKey, error) {
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key %q is %d bits, need at least %d", id, key.N.BitLen(), minRSABits)
+		}
+		return &SigningKey{ID: id, Algorithm: AlgRS256, signKey: key, verifyKey: &key.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Algorithm: AlgEdDSA, signKey: key, verifyKey: key.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+}
+
+func NewPublicKey(id string, public crypto.PublicKey) (*SigningKey, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key %q is %d bits, need at least %d", id, key.N.BitLen(), minRSABits)
+		}
+		return &SigningKey{ID: id, Algorithm: AlgRS256, verifyKey: key}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{ID: id, Algorithm: AlgEdDSA, verifyKey: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
+	}
+}
+
+// ParsePEMKey accepts PKCS#8 and PKCS#1 private keys as well as PKIX public
+// keys.
+func ParsePEMKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in key %q", id)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse private key %q: %v", id, err)
+		}
+		return NewPrivateKey(id, key)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse private key %q: %v", id, err)
+		}
+		return NewPrivateKey(id, key)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse public key %q: %v", id, err)
+		}
+		return NewPublicKey(id, key)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in key %q", block.Type, id)
+	}
+}
+
+func (s *SigningKey) CanSign() bool {
+	return s.signKey != nil
+}
+
+func (s *SigningKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(s.Algorithm)
+}
+
+// KeyRing holds every key tokens may be verified with and the single key new
+// tokens are signed with. Keeping the previous signing key in the ring after
+// a rotation lets tokens it issued verify until they expire.
+type KeyRing struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string]*SigningKey
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]*SigningKey)}
+}
+
+// LoadKeyRing reads every *.pem file in dir, using the file name without the
+// extension as the key id, and signs with the key activeID. activeID may be
+// empty when the directory holds exactly one private key.
+func LoadKeyRing(dir, activeID string) (*KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list keys: %v", err)
+	}
+
+	ring := NewKeyRing()
+	var signers []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read key: %v", err)
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := ParsePEMKey(id, data)
+		if err != nil {
+			return nil, err
+		}
+		if err := ring.Add(key); err != nil {
+			return nil, err
+		}
+		if key.CanSign() {
+			signers = append(signers, id)
+		}
+	}
+
+	if activeID == "" {
+		if len(signers) != 1 {
+			return nil, fmt.Errorf("found %d private keys in %s, set the active key id explicitly", len(signers), dir)
+		}
+		activeID = signers[0]
+	}
+
+	if err := ring.SetActive(activeID); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+func (k *KeyRing) Add(key *SigningKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[key.ID]; ok {
+		return fmt.Errorf("duplicate key id %q", key.ID)
+	}
+	k.keys[key.ID] = key
+	return nil
+}
+
+func (k *KeyRing) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if id == k.active {
+		return fmt.Errorf("cannot remove active key %q", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+func (k *KeyRing) SetActive(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, ok := k.keys[id]
+	if !ok {
+		return fmt.Errorf("unknown key id %q", id)
+	}
+	if !key.CanSign() {
+		return fmt.Errorf("key %q has no private part", id)
+	}
+	k.active = id
+	return nil
+}
+
+func (k *KeyRing) signingKey() (*SigningKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[k.active]
+	if !ok {
+		return nil, fmt.Errorf("key ring has no active key")
+	}
+	return key, nil
+}
+
+func (k *KeyRing) lookup(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+
+	k.mu.RLock()
+	key, ok := k.keys[id]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("algorithm %s does not match key %q", token.Method.Alg(), id)
+	}
+	return key.verifyKey, nil
+}
+
+// Tokens other than access tokens carry a token_use claim so they can never
+// be presented in place of one.
+const (
+	tokenUseAccess = ""
+	tokenUseMFA    = "mfa"
+)
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	TokenUse string `json:"token_use,omitempty"`
+	Role     string `json:"role,omitempty"`
+	// Scope and ClientID are set on tokens issued to third-party clients,
+	// as in RFC 9068.
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// Claims are what a validated access token says about its holder.
+type Claims struct {
+	UserID uuid.UUID
+	// Role is the role the user held when the token was issued. Tokens
+	// issued before roles existed carry RoleUser.
+	Role string
+	// ClientID is the third-party client the token was issued to, and
+	// Scopes what the user allowed it to do. Both are empty for tokens
+	// from a user's own login.
+	ClientID  string
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// MakeJWT issues an access token for userID that records role, so it can be
+// authorized without a database lookup.
+func (k *KeyRing) MakeJWT(userID uuid.UUID, role string, expiresIn time.Duration) (string, error) {
+	return k.sign(tokenClaims{TokenUse: tokenUseAccess, Role: role}, userID, expiresIn)
+}
+
+// MakeClientJWT issues an access token for userID to a third-party client,
+// limited to scopes.
+func (k *KeyRing) MakeClientJWT(userID uuid.UUID, clientID string, scopes []string, expiresIn time.Duration) (string, error) {
+	claims := tokenClaims{
+		TokenUse: tokenUseAccess,
+		Role:     RoleUser,
+		Scope:    strings.Join(scopes, " "),
+		ClientID: clientID,
+	}
+	return k.sign(claims, userID, expiresIn)
+}
+
+func (k *KeyRing) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	claims, err := k.ValidateAccessToken(tokenString)
+	return claims.UserID, err
+}
+
+func (k *KeyRing) ValidateAccessToken(tokenString string) (Claims, error) {
+	claims, err := k.validate(tokenString, tokenUseAccess)
+	if err != nil {
+		return Claims{}, err
+	}
+	if claims.Role == "" {
+		claims.Role = RoleUser
+	}
+	return claims, nil
+}
+
+// sign fills in the registered claims of claims and signs them with the
+// active key.
+func (k *KeyRing) sign(claims tokenClaims, userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	key, err := k.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	issued := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{Issuer: "chirpy",
+		IssuedAt:  jwt.NewNumericDate(issued),
+		ExpiresAt: jwt.NewNumericDate(issued.Add(expiresIn)),
+		Subject:   userID.String()}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	res, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+func (k *KeyRing) validate(tokenString, use string) (Claims, error) {
+	var claims tokenClaims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, k.lookup,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}))
+	if err != nil {
+		return Claims{}, fmt.Errorf("could not parse token: %v", err)
+	}
+
+	idString, err := token.Claims.GetSubject()
+	if err != nil {
+		return Claims{}, fmt.Errorf("could not get subject from token: %v", err)
+	}
+	issuer, err := token.Claims.GetIssuer()
+	if err != nil {
+		return Claims{}, fmt.Errorf("could not get issuer from token: %v", err)
+	}
+	if issuer != "chirpy" {
+		return Claims{}, fmt.Errorf("invalid issuer: %v", issuer)
+	}
+	if claims.TokenUse != use {
+		return Claims{}, fmt.Errorf("invalid token use: %q", claims.TokenUse)
+	}
+
+	userID, err := uuid.Parse(idString)
+	if err != nil {
+		return Claims{}, fmt.Errorf("could not parse uuid: %v", err)
+	}
+	validated := Claims{UserID: userID, Role: claims.Role, ClientID: claims.ClientID}
+	if claims.ClientID != "" {
+		validated.Scopes = strings.Fields(claims.Scope)
+	}
+	if claims.IssuedAt != nil {
+		validated.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		validated.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return validated, nil
+}
+
+// JSONWebKey is the public half of a key as described in RFC 7517.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of the ring. Shared secrets are never
+// published.
+func (k *KeyRing) JWKS() JSONWebKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range k.keys {
+		jwk := JSONWebKey{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyRingRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate rsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate ed25519 key: %v", err)
+	}
+
+	for id, private := range map[string]interface{}{"rsa": rsaKey, "ed": edKey} {
+		key, err := NewPrivateKey(id, private)
+		if err != nil {
+			t.Fatalf("could not create key %s: %v", id, err)
+		}
+		ring := NewKeyRing()
+		ring.Add(key)
+		ring.SetActive(id)
+
+		want := uuid.New()
+		token, err := ring.MakeJWT(want, RoleUser, time.Hour)
+		if err != nil {
+			t.Fatalf("could not make JWT with %s: %v", id, err)
+		}
+		got, err := ring.ValidateJWT(token)
+		if err != nil {
+			t.Fatalf("could not validate JWT with %s: %v", id, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %v want %v", id, got, want)
+		}
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+	oldSigning, _ := NewPrivateKey("old", oldKey)
+	newSigning, _ := NewPrivateKey("new", newKey)
+
+	ring := NewKeyRing()
+	ring.Add(oldSigning)
+	ring.SetActive("old")
+	userID := uuid.New()
+	oldToken, err := ring.MakeJWT(userID, RoleUser, time.Hour)
+	if err != nil {
+		t.Fatalf("could not make JWT: %v", err)
+	}
+
+	ring.Add(newSigning)
+	ring.SetActive("new")
+	if _, err := ring.ValidateJWT(oldToken); err != nil {
+		t.Errorf("token from retired key should still validate: %v", err)
+	}
+
+	ring.Remove("old")
+	if _, err := ring.ValidateJWT(oldToken); err == nil {
+		t.Errorf("token from removed key should not validate")
+	}
+
+	if got := len(ring.JWKS().Keys); got != 1 {
+		t.Errorf("got %d published keys want 1", got)
+	}
+}
+
+func TestKeyRingRejectsForeignAlgorithm(t *testing.T) {
+	hmacToken, err := MakeJWT(uuid.New(), "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("could not make JWT: %v", err)
+	}
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := NewPrivateKey("", edKey)
+	ring := NewKeyRing()
+	ring.Add(key)
+	ring.SetActive("")
+	if _, err := ring.ValidateJWT(hmacToken); err == nil {
+		t.Errorf("HS256 token validated against an EdDSA key")
+	}
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("could not marshal key: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, "2025-01.pem"), data, 0600); err != nil {
+		t.Fatalf("could not write key: %v", err)
+	}
+
+	ring, err := LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatalf("could not load key ring: %v", err)
+	}
+
+	jwks := ring.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "2025-01" || jwks.Keys[0].Crv != "Ed25519" {
+		t.Errorf("unexpected JWKS: %+v", jwks)
+	}
+}
+
+func TestAccessTokenRole(t *testing.T) {
+	ring := hmacRing("secret")
+	userID := uuid.New()
+
+	token, err := ring.MakeJWT(userID, RoleModerator, time.Minute)
+	if err != nil {
+		t.Fatalf("could not make JWT: %v", err)
+	}
+	claims, err := ring.ValidateAccessToken(token)
+	if err != nil || claims.UserID != userID || claims.Role != RoleModerator {
+		t.Errorf("got %+v, %v want %v as %s", claims, err, userID, RoleModerator)
+	}
+
+	legacy, _ := ring.sign(tokenClaims{TokenUse: tokenUseAccess}, userID, time.Minute)
+	if claims, _ := ring.ValidateAccessToken(legacy); claims.Role != RoleUser {
+		t.Errorf("token without a role got %q want %q", claims.Role, RoleUser)
+	}
+}
+
+func TestClientJWTScopes(t *testing.T) {
+	ring := hmacRing("secret")
+	userID := uuid.New()
+
+	token, err := ring.MakeClientJWT(userID, "client-1", []string{ScopeChirpsRead, ScopeChirpsWrite}, time.Minute)
+	if err != nil {
+		t.Fatalf("could not make JWT: %v", err)
+	}
+	claims, err := ring.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("could not validate JWT: %v", err)
+	}
+	if claims.ClientID != "client-1" || len(claims.Scopes) != 2 || claims.Scopes[1] != ScopeChirpsWrite {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	session, _ := ring.MakeJWT(userID, RoleUser, time.Minute)
+	if claims, _ := ring.ValidateAccessToken(session); claims.Scopes != nil || claims.ClientID != "" {
+		t.Errorf("session token has client claims %+v", claims)
+	}
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MFA challenges are signed with a key of their own that is never part of a
+// KeyRing, so it is never published in the JWKS, and carry an audience of
+// their own. Whoever verifies access tokens with the published keys can
+// therefore never take a challenge, which only proves the password, for an
+// access token.
+const mfaAudience = "chirpy-mfa"
+
+// MFAChallenge is what a challenge remembers of the first step of a login.
+type MFAChallenge struct {
+	UserID uuid.UUID
+	// ExpiresIn is the access token lifetime in seconds the client asked
+	// for when it logged in, or zero for the default.
+	ExpiresIn int
+}
+
+type mfaClaims struct {
+	jwt.RegisteredClaims
+	TokenUse  string `json:"token_use"`
+	ExpiresIn int    `json:"login_expires_in,omitempty"`
+}
+
+// MFATokenKey derives the key MFA challenges are signed with from secret,
+// so that the secret itself is never used to sign anything.
+func MFATokenKey(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("chirpy mfa challenge"))
+	return mac.Sum(nil)
+}
+
+// MakeMFAToken issues the short-lived challenge a user exchanges, together
+// with a second factor, for real tokens.
+func MakeMFAToken(key []byte, challenge MFAChallenge, expiresIn time.Duration) (string, error) {
+	issued := time.Now()
+	claims := mfaClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: "chirpy",
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			IssuedAt:  jwt.NewNumericDate(issued),
+			ExpiresAt: jwt.NewNumericDate(issued.Add(expiresIn)),
+			Subject:   challenge.UserID.String()},
+		TokenUse:  tokenUseMFA,
+		ExpiresIn: challenge.ExpiresIn,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+func ValidateMFAToken(key []byte, tokenString string) (MFAChallenge, error) {
+	var claims mfaClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims,
+		func(*jwt.Token) (interface{}, error) { return key, nil },
+		jwt.WithValidMethods([]string{AlgHS256}),
+		jwt.WithIssuer("chirpy"),
+		jwt.WithAudience(mfaAudience))
+	if err != nil {
+		return MFAChallenge{}, fmt.Errorf("could not parse token: %v", err)
+	}
+	if claims.TokenUse != tokenUseMFA {
+		return MFAChallenge{}, fmt.Errorf("invalid token use: %q", claims.TokenUse)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return MFAChallenge{}, fmt.Errorf("could not parse uuid: %v", err)
+	}
+	return MFAChallenge{UserID: userID, ExpiresIn: claims.ExpiresIn}, nil
+}
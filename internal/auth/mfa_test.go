@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMFATokenIsNotAnAccessToken(t *testing.T) {
+	ring := hmacRing("secret")
+	key := MFATokenKey([]byte("secret"))
+	challenge := MFAChallenge{UserID: uuid.New(), ExpiresIn: 600}
+
+	mfaToken, err := MakeMFAToken(key, challenge, time.Minute)
+	if err != nil {
+		t.Fatalf("could not make MFA token: %v", err)
+	}
+	if _, err := ring.ValidateJWT(mfaToken); err == nil {
+		t.Errorf("MFA token accepted as access token")
+	}
+	if got, err := ValidateMFAToken(key, mfaToken); err != nil || got != challenge {
+		t.Errorf("got %+v, %v want %+v", got, err, challenge)
+	}
+
+	accessToken, _ := ring.MakeJWT(challenge.UserID, RoleUser, time.Minute)
+	if _, err := ValidateMFAToken(key, accessToken); err == nil {
+		t.Errorf("access token accepted as MFA token")
+	}
+}
+
+func TestMFATokenKey(t *testing.T) {
+	challenge := MFAChallenge{UserID: uuid.New()}
+	mfaToken, _ := MakeMFAToken(MFATokenKey([]byte("secret")), challenge, time.Minute)
+
+	if _, err := ValidateMFAToken([]byte("secret"), mfaToken); err == nil {
+		t.Errorf("MFA token accepted with the secret it was derived from")
+	}
+	if _, err := ValidateMFAToken(MFATokenKey([]byte("other")), mfaToken); err == nil {
+		t.Errorf("MFA token accepted with another key")
+	}
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms. Stored hashes identify their own algorithm:
+// argon2id hashes use the PHC string format
+// ($argon2id$v=19$m=65536,t=3,p=2$salt$hash) and bcrypt hashes use their
+// usual $2a$/$2b$ modular crypt format.
+const (
+	AlgArgon2id = "argon2id"
+	AlgBcrypt   = "bcrypt"
+)
+
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// PasswordHasher hashes new passwords with Algorithm and decides which
+// stored hashes are out of date.
+type PasswordHasher struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// DefaultPasswordHasher follows the OWASP recommendation for argon2id.
+var DefaultPasswordHasher = PasswordHasher{
+	Algorithm: AlgArgon2id,
+	Argon2: Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	},
+	BcryptCost: 12,
+}
+
+func HashPassword(password string) (string, error) {
+	return DefaultPasswordHasher.Hash(password)
+}
+
+// CheckPasswordHash compares password against a hash in any supported
+// format.
+func CheckPasswordHash(password, hash string) error {
+	if strings.HasPrefix(hash, "$"+AlgArgon2id+"$") {
+		return checkArgon2id(password, hash)
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
+
+func (h PasswordHasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case AlgArgon2id:
+		salt := make([]byte, h.Argon2.SaltLength)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", fmt.Errorf("could not generate salt: %v", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, h.Argon2.Iterations, h.Argon2.Memory, h.Argon2.Parallelism, h.Argon2.KeyLength)
+		return encodeArgon2id(h.Argon2, salt, key), nil
+	case AlgBcrypt:
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("could not hash password: %v", err)
+		}
+		return string(hashed), nil
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm %q", h.Algorithm)
+	}
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or
+// with parameters other than h's, so it should be replaced the next time
+// the password is known.
+func (h PasswordHasher) NeedsRehash(hash string) bool {
+	switch h.Algorithm {
+	case AlgArgon2id:
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		return params.Memory != h.Argon2.Memory ||
+			params.Iterations != h.Argon2.Iterations ||
+			params.Parallelism != h.Argon2.Parallelism ||
+			uint32(len(salt)) != h.Argon2.SaltLength ||
+			uint32(len(key)) != h.Argon2.KeyLength
+	case AlgBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.BcryptCost
+	default:
+		return false
+	}
+}
+
+func checkArgon2id(password, hash string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func encodeArgon2id(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgArgon2id,
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgArgon2id {
+		return params, nil, nil, errors.New("malformed argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id parameters: %v", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id salt: %v", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("malformed argon2id key")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
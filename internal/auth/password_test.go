@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+var testArgon2 = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHashRoundTrip(t *testing.T) {
+	hashers := map[string]PasswordHasher{
+		"argon2id": {Algorithm: AlgArgon2id, Argon2: testArgon2},
+		"bcrypt":   {Algorithm: AlgBcrypt, BcryptCost: 4},
+	}
+
+	for name, hasher := range hashers {
+		hash, err := hasher.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s: could not hash: %v", name, err)
+		}
+		if err := CheckPasswordHash("correct horse", hash); err != nil {
+			t.Errorf("%s: correct password rejected: %v", name, err)
+		}
+		if err := CheckPasswordHash("wrong horse", hash); !errors.Is(err, ErrPasswordMismatch) {
+			t.Errorf("%s: got %v want ErrPasswordMismatch", name, err)
+		}
+		if hasher.NeedsRehash(hash) {
+			t.Errorf("%s: fresh hash needs rehash", name)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon := PasswordHasher{Algorithm: AlgArgon2id, Argon2: testArgon2}
+	bcryptHasher := PasswordHasher{Algorithm: AlgBcrypt, BcryptCost: 4}
+
+	bcryptHash, _ := bcryptHasher.Hash("password")
+	if !argon.NeedsRehash(bcryptHash) {
+		t.Errorf("bcrypt hash should be upgraded to argon2id")
+	}
+
+	argonHash, _ := argon.Hash("password")
+	stronger := argon
+	stronger.Argon2.Iterations = 2
+	if !stronger.NeedsRehash(argonHash) {
+		t.Errorf("hash with fewer iterations should be rehashed")
+	}
+
+	costlier := PasswordHasher{Algorithm: AlgBcrypt, BcryptCost: 5}
+	if !costlier.NeedsRehash(bcryptHash) {
+		t.Errorf("hash with lower cost should be rehashed")
+	}
+}
+
+func TestCheckPasswordHashMalformed(t *testing.T) {
+	for _, hash := range []string{"", "$argon2id$v=19$m=1024,t=1,p=1$", "$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5"} {
+		if err := CheckPasswordHash("password", hash); err == nil {
+			t.Errorf("malformed hash %q accepted", hash)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy rules reported in PolicyViolation.Rule.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleStrength  = "strength"
+	RuleBreached  = "breached"
+)
+
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy decides which passwords users may choose. MinScore is on
+// the 0-4 scale of ScorePassword. Breached is optional.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	MinScore  int
+	Breached  *BloomFilter
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength: 8,
+	MaxLength: 128,
+	MinScore:  2,
+}
+
+// Check returns every rule password breaks. userInputs are strings such
+// as the email address that make a password easy to guess for this user.
+func (p PasswordPolicy) Check(password string, userInputs ...string) []PolicyViolation {
+	violations := []PolicyViolation{}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("password must be at most %d characters long", p.MaxLength),
+		})
+	}
+	if ScorePassword(password, userInputs...) < p.MinScore {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleStrength,
+			Message: "password is too easy to guess",
+		})
+	}
+	if p.Breached != nil && p.Breached.Test(BreachedPasswordKey(password)) {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleBreached,
+			Message: "password has appeared in a data breach",
+		})
+	}
+
+	return violations
+}
+
+// Thresholds on log10(guesses) for scores 1 to 4, as used by zxcvbn.
+var scoreThresholds = []float64{3, 6, 8, 10}
+
+// ScorePassword estimates how hard password is to guess on a scale from 0
+// (trivial) to 4 (strong). Like zxcvbn it looks for the patterns attackers
+// try first: common passwords and words, the user's own details, keyboard
+// walks, sequences and repeats. The rest is costed as brute force.
+func ScorePassword(password string, userInputs ...string) int {
+	guesses := estimateGuesses(password, userInputs)
+	score := 0
+	for _, threshold := range scoreThresholds {
+		if guesses >= threshold {
+			score++
+		}
+	}
+	return score
+}
+
+type rankedWord struct {
+	word string
+	rank int
+}
+
+// estimateGuesses returns log10 of the estimated number of guesses.
+func estimateGuesses(password string, userInputs []string) float64 {
+	runes := []rune(strings.ToLower(password))
+	original := []rune(password)
+	if len(runes) != len(original) {
+		original = runes
+	}
+
+	// the user's own details are tried before anything else
+	var words []rankedWord
+	for _, input := range userInputs {
+		for _, part := range strings.FieldsFunc(strings.ToLower(input), isSeparator) {
+			if len(part) >= 3 {
+				words = append(words, rankedWord{word: part, rank: len(words) + 1})
+			}
+		}
+	}
+	for i, word := range commonPasswords {
+		words = append(words, rankedWord{word: word, rank: i + 1})
+	}
+
+	guesses := 0.0
+	patterns := 0
+	for i := 0; i < len(runes); {
+		length, cost := longestPattern(runes[i:], original[i:], words)
+		if length == 0 {
+			guesses += math.Log10(float64(charsetSize(original[i : i+1])))
+			i++
+			continue
+		}
+		guesses += cost
+		patterns++
+		i += length
+	}
+
+	// each extra pattern means the attacker has to guess how they combine
+	if patterns > 1 {
+		guesses += math.Log10(float64(patterns))
+	}
+	return guesses
+}
+
+func longestPattern(runes, original []rune, words []rankedWord) (int, float64) {
+	bestLength, bestCost := 0, 0.0
+	consider := func(length int, cost float64) {
+		if length > bestLength {
+			bestLength, bestCost = length, cost
+		}
+	}
+
+	normalized := unleet(runes)
+	for _, word := range words {
+		length := len(word.word)
+		if length > len(normalized) || length <= bestLength {
+			continue
+		}
+		if string(normalized[:length]) == word.word {
+			consider(length, dictionaryCost(word.rank, runes[:length], original[:length]))
+		}
+	}
+
+	if length := repeatLength(runes); length >= 3 {
+		consider(length, math.Log10(float64(charsetSize(original[:1])*length)))
+	}
+	if length := sequenceLength(runes); length >= 3 {
+		consider(length, math.Log10(float64(26*length)))
+	}
+	if length := keyboardLength(runes); length >= 4 {
+		consider(length, math.Log10(float64(len(keyboardRows)*2*length)))
+	}
+	if isRecentYear(runes) {
+		consider(4, math.Log10(yearSpace))
+	}
+
+	return bestLength, bestCost
+}
+
+// dictionaryCost prices a word by its rank in the list, plus the variations
+// an attacker would try on it: capitals and common character substitutions.
+func dictionaryCost(rank int, lower, original []rune) float64 {
+	cost := math.Log10(float64(rank + 1))
+	for i := range original {
+		if original[i] != lower[i] {
+			cost += 0.3
+			break
+		}
+	}
+	for _, r := range lower {
+		if _, ok := leetSubstitutions[r]; ok {
+			cost += 0.3
+			break
+		}
+	}
+	return cost
+}
+
+const yearSpace = 200
+
+func isRecentYear(runes []rune) bool {
+	if len(runes) < 4 {
+		return false
+	}
+	prefix := string(runes[:2])
+	return (prefix == "19" || prefix == "20") && unicode.IsDigit(runes[2]) && unicode.IsDigit(runes[3])
+}
+
+func repeatLength(runes []rune) int {
+	length := 1
+	for length < len(runes) && runes[length] == runes[0] {
+		length++
+	}
+	return length
+}
+
+func sequenceLength(runes []rune) int {
+	if len(runes) < 2 {
+		return len(runes)
+	}
+	step := runes[1] - runes[0]
+	if step != 1 && step != -1 {
+		return 1
+	}
+	length := 2
+	for length < len(runes) && runes[length]-runes[length-1] == step {
+		length++
+	}
+	return length
+}
+
+func keyboardLength(runes []rune) int {
+	best := 0
+	for _, row := range keyboardRows {
+		for _, direction := range []string{row, reverse(row)} {
+			start := strings.IndexRune(direction, runes[0])
+			if start < 0 {
+				continue
+			}
+			length := 0
+			for length < len(runes) && start+length < len(direction) && rune(direction[start+length]) == runes[length] {
+				length++
+			}
+			best = max(best, length)
+		}
+	}
+	return best
+}
+
+func charsetSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return max(size, 10)
+}
+
+func unleet(runes []rune) []rune {
+	normalized := make([]rune, len(runes))
+	for i, r := range runes {
+		if sub, ok := leetSubstitutions[r]; ok {
+			r = sub
+		}
+		normalized[i] = r
+	}
+	return normalized
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+var leetSubstitutions = map[rune]rune{
+	'4': 'a',
+	'@': 'a',
+	'8': 'b',
+	'3': 'e',
+	'1': 'i',
+	'!': 'i',
+	'0': 'o',
+	'5': 's',
+	'$': 's',
+	'7': 't',
+}
+
+var keyboardRows = []string{
+	"1234567890-=",
+	"qwertyuiop[]",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+var commonPasswords = []string{
+	"password", "passw0rd", "letmein", "welcome", "admin", "administrator",
+	"login", "master", "monkey", "dragon", "football", "baseball", "soccer",
+	"hockey", "superman", "batman", "trustno1", "sunshine", "princess",
+	"shadow", "michael", "jennifer", "jordan", "hunter", "ranger", "buster",
+	"thomas", "charlie", "summer", "winter", "spring", "autumn", "secret",
+	"freedom", "whatever", "iloveyou", "love", "qwerty", "azerty", "abc",
+	"starwars", "pokemon", "computer", "internet", "access", "flower",
+	"cheese", "pepper", "ginger", "cookie", "chocolate", "banana", "orange",
+	"purple", "yellow", "silver", "golden", "tigger", "killer", "hello",
+	"chirpy", "chirp", "twitter", "google", "apple", "samsung", "changeme",
+	"default", "guest", "user", "test", "root", "pass", "god", "money",
+	"angel", "lovely", "family", "friends", "matrix", "mustang", "corvette",
+	"ferrari", "harley", "diamond", "maggie", "ashley", "nicole", "daniel",
+	"andrew", "joshua", "robert", "william", "george", "anthony", "richard",
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScorePassword(t *testing.T) {
+	tests := []struct {
+		password string
+		inputs   []string
+		maxScore int
+		minScore int
+	}{
+		{password: "", maxScore: 0},
+		{password: "password", maxScore: 1},
+		{password: "P@ssw0rd", maxScore: 1},
+		{password: "aaaaaaaaaaaa", maxScore: 1},
+		{password: "abcdefgh", maxScore: 1},
+		{password: "qwertyuiop", maxScore: 1},
+		{password: "12345678", maxScore: 1},
+		{password: "lane.kim2024", inputs: []string{"lane.kim@example.com"}, maxScore: 1},
+		{password: "Tr0ub4dor&3xq", minScore: 3, maxScore: 4},
+		{password: "correct horse battery staple", minScore: 4, maxScore: 4},
+	}
+
+	for _, tc := range tests {
+		got := ScorePassword(tc.password, tc.inputs...)
+		if got < tc.minScore || got > tc.maxScore {
+			t.Errorf("ScorePassword(%q) = %d want between %d and %d", tc.password, got, tc.minScore, tc.maxScore)
+		}
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	breached := NewBloomFilter(10, 0.001)
+	breached.Add(BreachedPasswordKey("correct horse battery staple"))
+	policy := DefaultPasswordPolicy
+	policy.Breached = breached
+
+	rules := func(password string) string {
+		var names []string
+		for _, violation := range policy.Check(password) {
+			names = append(names, violation.Rule)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := rules(""); got != "min_length,strength" {
+		t.Errorf("empty password: got %q", got)
+	}
+	if got := rules("correct horse battery staple"); got != "breached" {
+		t.Errorf("breached password: got %q", got)
+	}
+	if got := rules("vivid-otter-kettle-93"); got != "" {
+		t.Errorf("good password: got %q", got)
+	}
+}
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("%X:%d", sha1.Sum([]byte(fmt.Sprintf("leaked%d", i))), i+1))
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")), 0600); err != nil {
+		t.Fatalf("could not write corpus: %v", err)
+	}
+
+	filter, err := LoadBreachedPasswords(path, 10)
+	if err != nil {
+		t.Fatalf("could not load corpus: %v", err)
+	}
+	if !filter.Test(BreachedPasswordKey("leaked50")) {
+		t.Errorf("leaked50 not found")
+	}
+	if filter.Test(BreachedPasswordKey("leaked2")) {
+		t.Errorf("leaked2 is below the minimum count")
+	}
+	if filter.Test(BreachedPasswordKey("never leaked")) {
+		t.Errorf("unexpected false positive")
+	}
+}
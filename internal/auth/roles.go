@@ -0,0 +1,25 @@
+package auth
+
+import "slices"
+
+// Roles a user can hold, from least to most privileged. Each role may do
+// everything the roles before it may.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// HasRole reports whether role grants at least the privileges of required.
+// Unknown roles grant nothing.
+func HasRole(role, required string) bool {
+	have := slices.Index(Roles, role)
+	want := slices.Index(Roles, required)
+	return have >= 0 && want >= 0 && have >= want
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeAccountRead  = "account:read"
+	ScopeAccountWrite = "account:write"
+)
+
+var Scopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeAccountRead, ScopeAccountWrite}
+
+// ValidateScopes rejects unknown scopes and returns the rest sorted and
+// without duplicates.
+func ValidateScopes(scopes []string) ([]string, error) {
+	valid := []string{}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		valid = append(valid, scope)
+	}
+	slices.Sort(valid)
+	return slices.Compact(valid), nil
+}
+
+// Personal access tokens are marked so they can be told apart from JWTs in
+// an Authorization header and recognised by secret scanners.
+const personalAccessTokenMarker = "chirpy_pat_"
+
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRandomToken()
+	if err != nil {
+		return "", err
+	}
+	return personalAccessTokenMarker + token, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenMarker)
+}
+
+// PersonalAccessTokenPrefix returns the lookup prefix of a personal access
+// token, taken from the random part rather than the fixed marker.
+func PersonalAccessTokenPrefix(token string) string {
+	return TokenPrefix(strings.TrimPrefix(token, personalAccessTokenMarker))
+}
@@ -0,0 +1,42 @@
+package auth
+
+import "time"
+
+// LockoutPolicy decides how long further attempts are refused after a run
+// of failed logins. The first FreeAttempts failures cost nothing, after that
+// the delay doubles from BaseDelay up to MaxDelay, and once LockoutThreshold
+// failures pile up the subject is locked out for LockoutDuration. A zero
+// LockoutThreshold never locks out.
+type LockoutPolicy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// ResetAfter is how long failures are remembered without new ones.
+	ResetAfter time.Duration
+}
+
+func (p LockoutPolicy) LockedOut(failures int) bool {
+	return p.LockoutThreshold > 0 && failures >= p.LockoutThreshold
+}
+
+// Delay returns how long to refuse attempts after the given number of
+// consecutive failures.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	if p.LockedOut(failures) {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
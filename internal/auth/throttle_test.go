@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	policy := LockoutPolicy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         10 * time.Second,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+	}
+
+	tests := map[int]time.Duration{
+		1:  0,
+		3:  0,
+		4:  time.Second,
+		5:  2 * time.Second,
+		6:  4 * time.Second,
+		7:  8 * time.Second,
+		8:  10 * time.Second,
+		9:  10 * time.Second,
+		10: 15 * time.Minute,
+		25: 15 * time.Minute,
+	}
+	for failures, want := range tests {
+		if got := policy.Delay(failures); got != want {
+			t.Errorf("%d failures: got %v want %v", failures, got, want)
+		}
+	}
+
+	policy.LockoutThreshold = 0
+	if got := policy.Delay(25); got != 10*time.Second {
+		t.Errorf("policy without lockout: got %v want %v", got, 10*time.Second)
+	}
+}
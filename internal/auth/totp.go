@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters from RFC 6238 that every authenticator app supports.
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTOTPSecret() (string, error) {
+	data := make([]byte, 20)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", fmt.Errorf("could not generate secret: %v", err)
+	}
+	return totpEncoding.EncodeToString(data), nil
+}
+
+func TOTPCounter(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// HOTP computes the RFC 4226 code of secret for counter.
+func HOTP(secret string, counter int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("could not decode secret: %v", err)
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// ValidateTOTP checks code against the time steps around t and returns the
+// counter it matched. Callers should reject counters that were already used
+// so a code cannot be replayed.
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := TOTPCounter(t)
+	for counter := current - totpSkew; counter <= current+totpSkew; counter++ {
+		expected, err := HOTP(secret, counter)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI authenticator apps read from
+// a QR code.
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(totpDigits))
+	values.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, values.Encode())
+}
+
+// GenerateRecoveryCodes returns n single-use codes formatted as xxxxx-xxxxx.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for range n {
+		data := make([]byte, 7)
+		_, err := rand.Read(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate recovery code: %v", err)
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(data))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode lets users type recovery codes without the dash or in
+// upper case.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
+
+// EncryptSecret seals plaintext with AES-GCM under a key derived from key.
+func EncryptSecret(plaintext string, key []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", fmt.Errorf("could not generate nonce: %v", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func DecryptSecret(ciphertext string, key []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("could not decode secret: %v", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("secret too short")
+	}
+
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt secret: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	derived := sha256.Sum256(key)
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
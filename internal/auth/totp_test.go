@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226 appendix D
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	want := []string{"755224", "287082", "359152", "969429", "338314"}
+	for counter, code := range want {
+		got, err := HOTP(secret, int64(counter))
+		if err != nil {
+			t.Fatalf("could not compute HOTP: %v", err)
+		}
+		if got != code {
+			t.Errorf("counter %d: got %v want %v", counter, got, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("could not generate secret: %v", err)
+	}
+
+	now := time.Now()
+	previous, _ := HOTP(secret, TOTPCounter(now)-1)
+	if counter, ok := ValidateTOTP(secret, previous, now); !ok || counter != TOTPCounter(now)-1 {
+		t.Errorf("code from the previous step was rejected")
+	}
+
+	stale, _ := HOTP(secret, TOTPCounter(now)-3)
+	if _, ok := ValidateTOTP(secret, stale, now); ok {
+		t.Errorf("code from three steps ago was accepted")
+	}
+}
+
+func TestEncryptSecret(t *testing.T) {
+	sealed, err := EncryptSecret("JBSWY3DPEHPK3PXP", []byte("key"))
+	if err != nil {
+		t.Fatalf("could not encrypt: %v", err)
+	}
+
+	got, err := DecryptSecret(sealed, []byte("key"))
+	if err != nil || got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("got %v, %v want JBSWY3DPEHPK3PXP", got, err)
+	}
+	if _, err := DecryptSecret(sealed, []byte("other key")); err == nil {
+		t.Errorf("decrypted with the wrong key")
+	}
+}
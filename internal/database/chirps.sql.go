@@ -7,27 +7,34 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id) VALUES (
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, in_reply_to, quote_of) VALUES (
   gen_random_uuid(), 
   NOW(),
   NOW(),
   $1,
-  $2
-) RETURNING id, created_at, updated_at, body, user_id
+  $2,
+  $3,
+  $4
+) RETURNING id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	QuoteOf   uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.InReplyTo, arg.QuoteOf)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -35,6 +42,79 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		pq.Array(&i.ThreadPath),
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const createChirpRevision = `-- name: CreateChirpRevision :exec
+INSERT INTO chirp_revisions(id, chirp_id, body, written_at, replaced_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  NOW()
+)
+`
+
+type CreateChirpRevisionParams struct {
+	ChirpID   uuid.UUID
+	Body      string
+	WrittenAt time.Time
+}
+
+func (q *Queries) CreateChirpRevision(ctx context.Context, arg CreateChirpRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createChirpRevision, arg.ChirpID, arg.Body, arg.WrittenAt)
+	return err
+}
+
+const createRechirp = `-- name: CreateRechirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, rechirp_of) VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  '',
+  $1,
+  $2
+)
+ON CONFLICT (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
+`
+
+type CreateRechirpParams struct {
+	UserID    uuid.UUID
+	RechirpOf uuid.NullUUID
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.RechirpOf)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		pq.Array(&i.ThreadPath),
+		&i.SearchVector,
 	)
 	return i, err
 }
@@ -43,7 +123,7 @@ const deleteChirp = `-- name: DeleteChirp :one
 DELETE FROM chirps
  WHERE id = $2
    AND user_id = $1
-  RETURNING id, created_at, updated_at, body, user_id
+  RETURNING id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
 `
 
 type DeleteChirpParams struct {
@@ -60,18 +140,178 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		pq.Array(&i.ThreadPath),
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const deleteChirpByID = `-- name: DeleteChirpByID :one
+DELETE FROM chirps
+ WHERE id = $1
+  RETURNING id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
+`
+
+func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, deleteChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		pq.Array(&i.ThreadPath),
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const deleteRechirp = `-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+ WHERE user_id = $1
+   AND rechirp_of = $2
+`
+
+type DeleteRechirpParams struct {
+	UserID    uuid.UUID
+	RechirpOf uuid.NullUUID
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.RechirpOf)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
+  FROM chirps
+ WHERE id = $1
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+`
+
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		pq.Array(&i.ThreadPath),
+		&i.SearchVector,
 	)
 	return i, err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
   FROM chirps
-ORDER BY created_at
+ WHERE id = $1
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   FOR UPDATE
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		pq.Array(&i.ThreadPath),
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getRechirp = `-- name: GetRechirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
+  FROM chirps
+ WHERE user_id = $1
+   AND rechirp_of = $2
+`
+
+type GetRechirpParams struct {
+	UserID    uuid.UUID
+	RechirpOf uuid.NullUUID
+}
+
+func (q *Queries) GetRechirp(ctx context.Context, arg GetRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getRechirp, arg.UserID, arg.RechirpOf)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		pq.Array(&i.ThreadPath),
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const listChirpAncestors = `-- name: ListChirpAncestors :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
+  FROM chirps
+ WHERE root_id = (SELECT x.root_id FROM chirps x WHERE x.id = $1)
+   AND thread_path = (SELECT x.thread_path[1:cardinality(chirps.thread_path)] FROM chirps x WHERE x.id = $1)
+   AND id <> $1
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+ORDER BY thread_path
+`
+
+// The ancestors of a chirp are the chirps whose thread_path its own starts
+// with, which still holds for those above a deleted reply.
+func (q *Queries) ListChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpAncestors, id)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +325,16 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.RootID,
+			&i.ReplyCount,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			pq.Array(&i.ThreadPath),
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -99,15 +349,139 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpByAuthor = `-- name: GetChirpByAuthor :many
-SELECT id, created_at, updated_at, body, user_id
+const listChirpDescendants = `-- name: ListChirpDescendants :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.root_id, chirps.reply_count, chirps.like_count, chirps.rechirp_of, chirps.quote_of, chirps.rechirp_count, chirps.quote_count, chirps.thread_path, chirps.search_vector, (cardinality(chirps.thread_path) - cardinality(parent.thread_path))::int AS depth
   FROM chirps
- WHERE user_id = $1
-ORDER BY created_at
+  JOIN chirps parent ON parent.root_id = chirps.root_id
+ WHERE parent.id = $1::uuid
+   AND cardinality(chirps.thread_path) > cardinality(parent.thread_path)
+   AND chirps.thread_path[1:cardinality(parent.thread_path)] = parent.thread_path
+   AND chirps.user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND ($2::text[] IS NULL OR chirps.thread_path > $2::text[])
+ORDER BY chirps.thread_path
+LIMIT $3
 `
 
-func (q *Queries) GetChirpByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpByAuthor, userID)
+type ListChirpDescendantsParams struct {
+	ChirpID    uuid.UUID
+	AfterPath  []string
+	MaxResults int32
+}
+
+type ListChirpDescendantsRow struct {
+	Chirp Chirp
+	Depth int32
+}
+
+// Descendants are found by thread_path rather than by following
+// in_reply_to, so replies below a deleted reply are still part of the
+// thread. Pages are keyed on the thread_path of the last reply.
+func (q *Queries) ListChirpDescendants(ctx context.Context, arg ListChirpDescendantsParams) ([]ListChirpDescendantsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpDescendants, arg.ChirpID, pq.Array(arg.AfterPath), arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListChirpDescendantsRow
+	for rows.Next() {
+		var i ListChirpDescendantsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.RootID,
+			&i.Chirp.ReplyCount,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpOf,
+			&i.Chirp.QuoteOf,
+			&i.Chirp.RechirpCount,
+			&i.Chirp.QuoteCount,
+			pq.Array(&i.Chirp.ThreadPath),
+			&i.Chirp.SearchVector,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpRevisions = `-- name: ListChirpRevisions :many
+SELECT id, chirp_id, body, written_at, replaced_at
+  FROM chirp_revisions
+ WHERE chirp_id = $1
+ORDER BY replaced_at DESC
+`
+
+func (q *Queries) ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]ChirpRevision, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpRevisions, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpRevision
+	for rows.Next() {
+		var i ChirpRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.Body,
+			&i.WrittenAt,
+			&i.ReplacedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
+  FROM chirps
+ WHERE user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND ($1::uuid[] IS NULL OR user_id = ANY($1::uuid[]))
+   AND ($2::timestamp IS NULL OR created_at >= $2::timestamp)
+   AND ($3::timestamp IS NULL OR created_at < $3::timestamp)
+   AND ($4::text IS NULL OR strpos(lower(body), lower($4::text)) > 0)
+   AND ($5::boolean IS NULL OR (body ~* 'https?://') = $5::boolean)
+   AND (NOT $6::boolean OR user_id IN (SELECT id FROM users WHERE is_chirpy_red))
+   AND ($7::timestamp IS NULL
+        OR (created_at, id) > ($7::timestamp, $8::uuid))
+ORDER BY created_at, id
+LIMIT $9
+`
+
+type ListChirpsAscParams struct {
+	AuthorIds      []uuid.UUID
+	Since          sql.NullTime
+	Until          sql.NullTime
+	Contains       sql.NullString
+	HasMedia       sql.NullBool
+	ChirpyRedOnly  bool
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	MaxResults     int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc, pq.Array(arg.AuthorIds), arg.Since, arg.Until, arg.Contains, arg.HasMedia, arg.ChirpyRedOnly, arg.AfterCreatedAt, arg.AfterID, arg.MaxResults)
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +495,16 @@ func (q *Queries) GetChirpByAuthor(ctx context.Context, userID uuid.UUID) ([]Chi
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.RootID,
+			&i.ReplyCount,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			pq.Array(&i.ThreadPath),
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -135,14 +519,137 @@ func (q *Queries) GetChirpByAuthor(ctx context.Context, userID uuid.UUID) ([]Chi
 	return items, nil
 }
 
-const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id
+const listChirpsByIDs = `-- name: ListChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
   FROM chirps
- WHERE id = $1
+ WHERE id = ANY($1::uuid[])
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+func (q *Queries) ListChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.RootID,
+			&i.ReplyCount,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			pq.Array(&i.ThreadPath),
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
+  FROM chirps
+ WHERE user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND ($1::uuid[] IS NULL OR user_id = ANY($1::uuid[]))
+   AND ($2::timestamp IS NULL OR created_at >= $2::timestamp)
+   AND ($3::timestamp IS NULL OR created_at < $3::timestamp)
+   AND ($4::text IS NULL OR strpos(lower(body), lower($4::text)) > 0)
+   AND ($5::boolean IS NULL OR (body ~* 'https?://') = $5::boolean)
+   AND (NOT $6::boolean OR user_id IN (SELECT id FROM users WHERE is_chirpy_red))
+   AND ($7::timestamp IS NULL
+        OR (created_at, id) < ($7::timestamp, $8::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $9
+`
+
+type ListChirpsDescParams struct {
+	AuthorIds      []uuid.UUID
+	Since          sql.NullTime
+	Until          sql.NullTime
+	Contains       sql.NullString
+	HasMedia       sql.NullBool
+	ChirpyRedOnly  bool
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	MaxResults     int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc, pq.Array(arg.AuthorIds), arg.Since, arg.Until, arg.Contains, arg.HasMedia, arg.ChirpyRedOnly, arg.AfterCreatedAt, arg.AfterID, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.RootID,
+			&i.ReplyCount,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			pq.Array(&i.ThreadPath),
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirp = `-- name: UpdateChirp :one
+UPDATE chirps
+   SET body = $1,
+       updated_at = NOW()
+ WHERE id = $2
+   AND ($3::int = 0
+        OR created_at > NOW() - make_interval(secs => $3::int))
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, root_id, reply_count, like_count, rechirp_of, quote_of, rechirp_count, quote_count, thread_path, search_vector
+`
+
+type UpdateChirpParams struct {
+	Body              string
+	ID                uuid.UUID
+	EditWindowSeconds int32
+}
+
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp, arg.Body, arg.ID, arg.EditWindowSeconds)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -150,6 +657,16 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		pq.Array(&i.ThreadPath),
+		&i.SearchVector,
 	)
 	return i, err
 }
@@ -11,28 +11,189 @@ import (
 	"github.com/google/uuid"
 )
 
-type Chirp struct {
+type AuditEvent struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
+	Event     string
+	Outcome   string
+	UserID    uuid.NullUUID
+	ActorID   uuid.NullUUID
+	IpAddress string
+	UserAgent string
+	Details   string
 }
 
-type RefreshToken struct {
-	Token     string
+type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	InReplyTo    uuid.NullUUID
+	RootID       uuid.UUID
+	ReplyCount   int32
+	LikeCount    int32
+	RechirpOf    uuid.NullUUID
+	QuoteOf      uuid.NullUUID
+	RechirpCount int32
+	QuoteCount   int32
+	ThreadPath   []string
+	SearchVector interface{}
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	WrittenAt  time.Time
+	ReplacedAt time.Time
+}
+
+type EmailChangeToken struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	NewEmail    string
+	TokenPrefix string
+	TokenHash   string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	UsedAt      sql.NullTime
+}
+
+type EmailVerificationToken struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	TokenPrefix string
+	TokenHash   string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	UsedAt      sql.NullTime
+}
+
+type LoginThrottle struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID
 	UserID    uuid.UUID
-	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	ID            uuid.UUID
+	CodePrefix    string
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	FamilyID      uuid.UUID
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	OwnerID      uuid.UUID
+	Name         string
+	RedirectUris []string
+	Scopes       []string
+	SecretHash   sql.NullString
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type OidcIdentity struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Issuer      string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+type OidcLoginState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	LinkUserID   uuid.NullUUID
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+type PasswordResetToken struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	TokenPrefix string
+	TokenHash   string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	UsedAt      sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Name        string
+	TokenPrefix string
+	TokenHash   string
+	Scopes      []string
+	CreatedAt   time.Time
+	LastUsedAt  sql.NullTime
+	ExpiresAt   sql.NullTime
+	RevokedAt   sql.NullTime
+}
+
+type RefreshToken struct {
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	ExpiresAt   time.Time
+	RevokedAt   sql.NullTime
+	FamilyID    uuid.UUID
+	ConsumedAt  sql.NullTime
+	ID          uuid.UUID
+	TokenPrefix string
+	TokenHash   string
+	UserAgent   string
+	IpAddress   string
+	LastUsedAt  time.Time
+	ClientID    uuid.NullUUID
+	Scopes      []string
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	EmailVerifiedAt sql.NullTime
+	Role            string
+	DeletedAt       sql.NullTime
+}
+
+type UserTotp struct {
+	UserID          uuid.UUID
+	Secret          string
+	CreatedAt       time.Time
+	EnabledAt       sql.NullTime
+	LastUsedCounter int64
 }
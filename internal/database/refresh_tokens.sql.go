@@ -7,72 +7,273 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const consumeRefreshToken = `-- name: ConsumeRefreshToken :one
+UPDATE refresh_tokens
+   SET consumed_at = NOW(),
+       updated_at = NOW()
+ WHERE id = $1
+   AND consumed_at IS NULL
+   AND revoked_at IS NULL
+RETURNING created_at, updated_at, user_id, expires_at, revoked_at, family_id, consumed_at, id, token_prefix, token_hash, user_agent, ip_address, last_used_at, client_id, scopes
+`
+
+func (q *Queries) ConsumeRefreshToken(ctx context.Context, id uuid.UUID) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, consumeRefreshToken, id)
+	var i RefreshToken
+	err := row.Scan(
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ConsumedAt,
+		&i.ID,
+		&i.TokenPrefix,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+	)
+	return i, err
+}
+
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at) VALUES
+INSERT INTO refresh_tokens(id, token_prefix, token_hash, created_at, updated_at, last_used_at, user_id, expires_at, family_id, user_agent, ip_address, client_id, scopes) VALUES
 (
+  gen_random_uuid(),
   $1,
+  $2,
   NOW(),
   NOW(),
-  $2,
-  NOW() + INTERVAL '60 day'
-) RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+  NOW(),
+  $3,
+  NOW() + INTERVAL '60 day',
+  $4,
+  $5,
+  $6,
+  $7,
+  $8
+) RETURNING created_at, updated_at, user_id, expires_at, revoked_at, family_id, consumed_at, id, token_prefix, token_hash, user_agent, ip_address, last_used_at, client_id, scopes
 `
 
 type CreateRefreshTokenParams struct {
-	Token  string
-	UserID uuid.UUID
+	TokenPrefix string
+	TokenHash   string
+	UserID      uuid.UUID
+	FamilyID    uuid.UUID
+	UserAgent   string
+	IpAddress   string
+	ClientID    uuid.NullUUID
+	Scopes      []string
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.TokenPrefix, arg.TokenHash, arg.UserID, arg.FamilyID, arg.UserAgent, arg.IpAddress, arg.ClientID, pq.Array(arg.Scopes))
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ConsumedAt,
+		&i.ID,
+		&i.TokenPrefix,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
-const getUserByToken = `-- name: GetUserByToken :one
-SELECT user_id
-  from refresh_tokens 
- WHERE revoked_at is NULL
-   AND token = $1
+const getRefreshTokensByPrefix = `-- name: GetRefreshTokensByPrefix :many
+SELECT created_at, updated_at, user_id, expires_at, revoked_at, family_id, consumed_at, id, token_prefix, token_hash, user_agent, ip_address, last_used_at, client_id, scopes
+  FROM refresh_tokens
+ WHERE token_prefix = $1
+   AND expires_at > NOW()
 `
 
-func (q *Queries) GetUserByToken(ctx context.Context, token string) (uuid.UUID, error) {
-	row := q.db.QueryRowContext(ctx, getUserByToken, token)
-	var user_id uuid.UUID
-	err := row.Scan(&user_id)
-	return user_id, err
+func (q *Queries) GetRefreshTokensByPrefix(ctx context.Context, tokenPrefix string) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getRefreshTokensByPrefix, tokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.FamilyID,
+			&i.ConsumedAt,
+			&i.ID,
+			&i.TokenPrefix,
+			&i.TokenHash,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastUsedAt,
+			&i.ClientID,
+			pq.Array(&i.Scopes),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listActiveSessions = `-- name: ListActiveSessions :many
+SELECT family_id,
+       (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = refresh_tokens.family_id)::timestamp AS started_at,
+       last_used_at,
+       expires_at,
+       user_agent,
+       ip_address
+  FROM refresh_tokens
+ WHERE user_id = $1
+   AND revoked_at IS NULL
+   AND consumed_at IS NULL
+   AND expires_at > NOW()
+ORDER BY last_used_at DESC
+`
+
+type ListActiveSessionsRow struct {
+	FamilyID   uuid.UUID
+	StartedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	UserAgent  string
+	IpAddress  string
+}
+
+func (q *Queries) ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]ListActiveSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListActiveSessionsRow
+	for rows.Next() {
+		var i ListActiveSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.StartedAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.UserAgent,
+			&i.IpAddress,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAllUserTokens = `-- name: RevokeAllUserTokens :exec
+UPDATE refresh_tokens
+   SET revoked_at = NOW(),
+       updated_at = NOW()
+ WHERE user_id = $1
+   AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllUserTokens, userID)
+	return err
 }
 
 const revokeToken = `-- name: RevokeToken :one
 UPDATE refresh_tokens
    SET revoked_at = NOW(),
        updated_at = NOW()
- WHERE token = $1
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+ WHERE id = $1
+RETURNING created_at, updated_at, user_id, expires_at, revoked_at, family_id, consumed_at, id, token_prefix, token_hash, user_agent, ip_address, last_used_at, client_id, scopes
 `
 
-func (q *Queries) RevokeToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, revokeToken, token)
+func (q *Queries) RevokeToken(ctx context.Context, id uuid.UUID) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, revokeToken, id)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ConsumedAt,
+		&i.ID,
+		&i.TokenPrefix,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
+
+const revokeTokenFamily = `-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+   SET revoked_at = NOW(),
+       updated_at = NOW()
+ WHERE family_id = $1
+   AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeTokenFamily, familyID)
+	return err
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+   SET revoked_at = NOW(),
+       updated_at = NOW()
+ WHERE family_id = $1
+   AND user_id = $2
+   AND revoked_at IS NULL
+`
+
+type RevokeUserSessionParams struct {
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserSession, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -7,11 +7,47 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const createExternalUser = `-- name: CreateExternalUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, email_verified_at)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  '',
+  $2
+)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
+`
+
+type CreateExternalUserParams struct {
+	Email           string
+	EmailVerifiedAt sql.NullTime
+}
+
+func (q *Queries) CreateExternalUser(ctx context.Context, arg CreateExternalUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createExternalUser, arg.Email, arg.EmailVerifiedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -49,7 +85,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateU
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
   FROM users
  WHERE users.email = $1
 `
@@ -64,10 +100,74 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
+  FROM users
+ WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :one
+UPDATE users
+   SET email_verified_at = NOW(),
+       updated_at = NOW()
+ WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
+`
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, markEmailVerified, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+ WHERE deleted_at < NOW() - make_interval(secs => $1::int)
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, graceSeconds int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, graceSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const resetUsers = `-- name: ResetUsers :exec
 DELETE FROM users WHERE TRUE
 `
@@ -77,23 +177,153 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
-const updateEmailAndPassword = `-- name: UpdateEmailAndPassword :one
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+   SET deleted_at = NULL,
+       updated_at = NOW()
+ WHERE id = $1
+   AND deleted_at > NOW() - make_interval(secs => $2::int)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
+`
+
+type RestoreUserParams struct {
+	ID           uuid.UUID
+	GraceSeconds int32
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, arg.ID, arg.GraceSeconds)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+   SET role = $2,
+       updated_at = NOW()
+ WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setUserRoleByEmail = `-- name: SetUserRoleByEmail :execrows
+UPDATE users
+   SET role = $2,
+       updated_at = NOW()
+ WHERE email = $1
+   AND email_verified_at IS NOT NULL
+   AND deleted_at IS NULL
+`
+
+type SetUserRoleByEmailParams struct {
+	Email string
+	Role  string
+}
+
+// Only verified addresses count, so that nobody can claim a role by
+// signing up with someone else's address first.
+func (q *Queries) SetUserRoleByEmail(ctx context.Context, arg SetUserRoleByEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRoleByEmail, arg.Email, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :execrows
+UPDATE users
+   SET deleted_at = NOW(),
+       updated_at = NOW()
+ WHERE id = $1
+   AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateEmail = `-- name: UpdateEmail :one
 UPDATE users
    SET email = $2,
-       hashed_password = $3,
+       email_verified_at = NOW(),
        updated_at = NOW()
  WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
 `
 
-type UpdateEmailAndPasswordParams struct {
+type UpdateEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) UpdateEmail(ctx context.Context, arg UpdateEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updatePassword = `-- name: UpdatePassword :one
+UPDATE users
+   SET hashed_password = $2,
+       updated_at = NOW()
+ WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
+`
+
+type UpdatePasswordParams struct {
 	ID             uuid.UUID
-	Email          string
 	HashedPassword string
 }
 
-func (q *Queries) UpdateEmailAndPassword(ctx context.Context, arg UpdateEmailAndPasswordParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateEmailAndPassword, arg.ID, arg.Email, arg.HashedPassword)
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updatePassword, arg.ID, arg.HashedPassword)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -102,6 +332,9 @@ func (q *Queries) UpdateEmailAndPassword(ctx context.Context, arg UpdateEmailAnd
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -111,7 +344,7 @@ UPDATE users
    SET is_chirpy_red = TRUE,
        updated_at = NOW()
  WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
 `
 
 func (q *Queries) UpgradeToRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -124,6 +357,9 @@ func (q *Queries) UpgradeToRed(ctx context.Context, id uuid.UUID) (User, error)
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,104 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers a message. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(msg Message) error
+}
+
+func format(from string, msg Message, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func validHeader(values ...string) error {
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("header value contains a line break")
+		}
+	}
+	return nil
+}
+
+// DirSender writes every message to its own .eml file in Dir instead of
+// delivering it. Meant for development and tests.
+type DirSender struct {
+	Dir  string
+	From string
+}
+
+func (d DirSender) Send(msg Message) error {
+	if err := validHeader(msg.To, msg.Subject); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("could not generate file name: %v", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	err := os.WriteFile(filepath.Join(d.Dir, name), format(d.From, msg, now), 0600)
+	if err != nil {
+		return fmt.Errorf("could not write message: %v", err)
+	}
+	return nil
+}
+
+type SMTPSender struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (s SMTPSender) Send(msg Message) error {
+	if err := validHeader(msg.To, msg.Subject); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := strings.Cut(s.Addr, ":")
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	err := smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, format(s.From, msg, time.Now()))
+	if err != nil {
+		return fmt.Errorf("could not send mail: %v", err)
+	}
+	return nil
+}
+
+// LogSender prints messages to the server log. It is the fallback when no
+// delivery is configured and should never be used in production.
+type LogSender struct{}
+
+func (LogSender) Send(msg Message) error {
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := DirSender{Dir: dir, From: "chirpy@example.com"}
+
+	err := sender.Send(Message{To: "user@example.com", Subject: "Hello", Body: "line one\nline two"})
+	if err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("got %d messages want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "To: user@example.com\r\n") || !strings.HasSuffix(string(data), "line one\r\nline two") {
+		t.Errorf("unexpected message:\n%s", data)
+	}
+}
+
+func TestDirSenderRejectsHeaderInjection(t *testing.T) {
+	sender := DirSender{Dir: t.TempDir()}
+	err := sender.Send(Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hello"})
+	if err == nil {
+		t.Errorf("sent a message with a line break in a header")
+	}
+}
@@ -0,0 +1,97 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWK decodes a public signing key as described in RFC 7517 and
+// RFC 8037.
+func parseJWK(raw json.RawMessage) (string, interface{}, error) {
+	var jwk jsonWebKey
+	err := json.Unmarshal(raw, &jwk)
+	if err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return "", nil, fmt.Errorf("key %q is not a signing key", jwk.Kid)
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return "", nil, err
+		}
+		if !e.IsInt64() {
+			return "", nil, errors.New("RSA exponent is too large")
+		}
+		return jwk.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return "", nil, errors.New("EC point is not on the curve")
+		}
+		return jwk.Kid, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return "", nil, errors.New("invalid Ed25519 key")
+		}
+		return jwk.Kid, ed25519.PublicKey(x), nil
+
+	default:
+		return "", nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
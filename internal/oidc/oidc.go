@@ -0,0 +1,326 @@
+// Package oidc signs users in with an external OpenID Connect provider
+// using the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes a provider registered with us as a client.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes requested besides "openid". Defaults to email and profile.
+	Scopes []string
+}
+
+// Metadata is the part of the provider's discovery document we use.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDToken holds the verified claims of an ID token.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Provider talks to one OpenID provider. Its discovery document and keys
+// are fetched on first use and cached.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// minKeyRefresh limits how often an unknown key id makes us fetch the
+// provider's keys again.
+const minKeyRefresh = time.Minute
+
+func NewProvider(config Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.Scopes == nil {
+		config.Scopes = []string{"email", "profile"}
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &Provider{config: config, client: client}
+}
+
+// Discover returns the provider's metadata, fetching it the first time.
+func (p *Provider) Discover(ctx context.Context) (Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return *p.metadata, nil
+	}
+
+	var metadata Metadata
+	err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", &metadata)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("could not discover provider: %v", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.config.Issuer {
+		return Metadata{}, fmt.Errorf("provider issuer %q does not match %q", metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return Metadata{}, errors.New("provider metadata is incomplete")
+	}
+
+	p.metadata = &metadata
+	return metadata, nil
+}
+
+// NewPKCE returns a code verifier and its S256 challenge as described in
+// RFC 7636.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomString returns 32 random bytes encoded for use in URLs, suitable
+// for state, nonce and code verifier values.
+func RandomString() (string, error) {
+	data := make([]byte, 32)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", fmt.Errorf("could not generate random string: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// AuthCodeURL is where the user's browser is sent to sign in.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.config.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token
+// that came with it.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (IDToken, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("could not reach token endpoint: %v", err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("could not decode token response: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return IDToken{}, fmt.Errorf("token endpoint returned %d: %s %s", res.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return IDToken{}, errors.New("token response has no id_token")
+	}
+
+	return p.VerifyIDToken(ctx, body.IDToken, nonce)
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string      `json:"nonce"`
+	AuthorizedParty string      `json:"azp"`
+	Email           string      `json:"email"`
+	EmailVerified   interface{} `json:"email_verified"`
+}
+
+// VerifyIDToken checks the signature of raw against the provider's keys and
+// its issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (IDToken, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	var claims idTokenClaims
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	_, err = parser.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return IDToken{}, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	if claims.Subject == "" {
+		return IDToken{}, errors.New("ID token has no subject")
+	}
+	if claims.Nonce != nonce {
+		return IDToken{}, errors.New("ID token nonce does not match")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return IDToken{}, errors.New("ID token was issued to another party")
+	}
+
+	verified := false
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+
+	return IDToken{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+	}, nil
+}
+
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < minKeyRefresh {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	// the provider may have rotated its keys since we last looked
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	err := p.getJSON(ctx, p.metadata.JWKSURI, &set)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch provider keys: %v", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, raw := range set.Keys {
+		id, key, err := parseJWK(raw)
+		if err != nil {
+			continue
+		}
+		keys[id] = key
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// lookupKey finds kid in the cached keys. Tokens without a kid are accepted
+// when the provider publishes a single key.
+func (p *Provider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", target, res.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
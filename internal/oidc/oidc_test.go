@@ -0,0 +1,216 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is a minimal OpenID provider that signs in a fixed user.
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+	// audience overrides the aud claim of issued ID tokens.
+	audience string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	m := &mockProvider{key: key, kid: "mock-1", codes: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.handleDiscovery)
+	mux.HandleFunc("GET /jwks", m.handleJWKS)
+	mux.HandleFunc("GET /authorize", m.handleAuthorize)
+	mux.HandleFunc("POST /token", m.handleToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(Metadata{
+		Issuer:                m.server.URL,
+		AuthorizationEndpoint: m.server.URL + "/authorize",
+		TokenEndpoint:         m.server.URL + "/token",
+		JWKSURI:               m.server.URL + "/jwks",
+	})
+}
+
+func (m *mockProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": m.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+// handleAuthorize signs the user in straight away and redirects back with
+// a code.
+func (m *mockProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	code, _ := RandomString()
+	m.mu.Lock()
+	m.codes[code] = query
+	m.mu.Unlock()
+
+	redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (m *mockProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	m.mu.Lock()
+	authorize, ok := m.codes[r.Form.Get("code")]
+	delete(m.codes, r.Form.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || PKCEChallenge(r.Form.Get("code_verifier")) != authorize.Get("code_challenge") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	audience := authorize.Get("client_id")
+	if m.audience != "" {
+		audience = m.audience
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "mock-user-1",
+		"aud":            audience,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          authorize.Get("nonce"),
+		"email":          "lane@example.com",
+		"email_verified": true,
+	})
+	token.Header["kid"] = m.kid
+	signed, _ := token.SignedString(m.key)
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer", "access_token": "opaque"})
+}
+
+// signIn runs the browser side of the flow and returns the code and state
+// the provider redirected back with.
+func signIn(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("could not sign in: %v", err)
+	}
+	res.Body.Close()
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := NewProvider(Config{
+		Issuer:      mock.server.URL,
+		ClientID:    "chirpy",
+		RedirectURL: "http://localhost:8080/callback",
+	}, nil)
+	ctx := context.Background()
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatalf("could not make PKCE pair: %v", err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "the-state", "the-nonce", challenge)
+	if err != nil {
+		t.Fatalf("could not build authorization URL: %v", err)
+	}
+	if !strings.Contains(authURL, "code_challenge_method=S256") {
+		t.Errorf("authorization URL does not use PKCE: %s", authURL)
+	}
+
+	code, state := signIn(t, authURL)
+	if state != "the-state" {
+		t.Errorf("got state %q", state)
+	}
+
+	idToken, err := provider.Exchange(ctx, code, verifier, "the-nonce")
+	if err != nil {
+		t.Fatalf("could not exchange code: %v", err)
+	}
+	want := IDToken{Issuer: mock.server.URL, Subject: "mock-user-1", Email: "lane@example.com", EmailVerified: true}
+	if idToken != want {
+		t.Errorf("got %+v want %+v", idToken, want)
+	}
+
+	if _, err := provider.Exchange(ctx, code, verifier, "the-nonce"); err == nil {
+		t.Errorf("code was redeemed twice")
+	}
+}
+
+func TestExchangeRejectsBadTokens(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := NewProvider(Config{Issuer: mock.server.URL, ClientID: "chirpy", RedirectURL: "http://localhost/cb"}, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		nonce    string
+		verifier func(string) string
+		audience string
+	}{
+		{name: "wrong nonce", nonce: "other-nonce"},
+		{name: "wrong verifier", nonce: "the-nonce", verifier: func(string) string { return "guessed" }},
+		{name: "wrong audience", nonce: "the-nonce", audience: "someone-else"},
+	}
+
+	for _, tc := range tests {
+		mock.audience = tc.audience
+		verifier, challenge, _ := NewPKCE()
+		authURL, _ := provider.AuthCodeURL(ctx, "state", "the-nonce", challenge)
+		code, _ := signIn(t, authURL)
+		if tc.verifier != nil {
+			verifier = tc.verifier(verifier)
+		}
+
+		if _, err := provider.Exchange(ctx, code, verifier, tc.nonce); err == nil {
+			t.Errorf("%s: exchange succeeded", tc.name)
+		}
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := NewProvider(Config{Issuer: mock.server.URL + "/other", ClientID: "chirpy"}, nil)
+	if _, err := provider.Discover(context.Background()); err == nil {
+		t.Errorf("discovery accepted a document for another issuer")
+	}
+}
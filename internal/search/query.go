@@ -0,0 +1,100 @@
+// Package search turns what users type into a search box into Postgres
+// full-text queries.
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptyQuery means a query has no words to search for, only
+// punctuation or exclusions.
+var ErrEmptyQuery = errors.New("query has no words to search for")
+
+// ParseQuery converts q into to_tsquery syntax. It understands
+//
+//	word      chirps containing word
+//	"a b c"   the phrase a b c
+//	word*     words starting with word, also inside phrases
+//	-word     chirps not containing word, also -"a phrase"
+//
+// Terms are combined with AND. Everything but letters and digits is
+// dropped, so the result is always a valid query.
+func ParseQuery(q string) (string, error) {
+	var terms []string
+	positive := false
+
+	for {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		negate := strings.HasPrefix(q, "-")
+		if negate {
+			q = q[1:]
+		}
+
+		var text string
+		if strings.HasPrefix(q, `"`) {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				// an unterminated phrase runs to the end of the query
+				text, q = q[1:], ""
+			} else {
+				text, q = q[1:end+1], q[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			text, q = q[:end], q[end:]
+		}
+
+		term := phraseTerm(text)
+		if term == "" {
+			continue
+		}
+		if negate {
+			term = "!" + term
+		} else {
+			positive = true
+		}
+		terms = append(terms, term)
+	}
+
+	if !positive {
+		return "", ErrEmptyQuery
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+// phraseTerm matches the words of text next to each other. Punctuation
+// splits words, so "e-mail" is the phrase e <-> mail, like Postgres's own
+// parser would see it.
+func phraseTerm(text string) string {
+	var words []string
+	for _, field := range strings.Fields(text) {
+		parts := strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(parts) == 0 {
+			continue
+		}
+		if strings.HasSuffix(field, "*") {
+			parts[len(parts)-1] += ":*"
+		}
+		words = append(words, parts...)
+	}
+
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		return words[0]
+	default:
+		return "(" + strings.Join(words, " <-> ") + ")"
+	}
+}
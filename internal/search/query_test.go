@@ -0,0 +1,44 @@
+package search
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "chirpy", want: "chirpy"},
+		{query: "  red   birds ", want: "red & birds"},
+		{query: `"chirpy red"`, want: "(chirpy <-> red)"},
+		{query: "chirp*", want: "chirp:*"},
+		{query: `"chirpy re*" bird`, want: "(chirpy <-> re:*) & bird"},
+		{query: "birds -cats", want: "birds & !cats"},
+		{query: `birds -"big cats"`, want: "birds & !(big <-> cats)"},
+		{query: "e-mail", want: "(e <-> mail)"},
+		{query: `"unterminated phrase`, want: "(unterminated <-> phrase)"},
+		{query: "it's & | ! (bad) :*", want: "(it <-> s) & bad"},
+		{query: "naïve café", want: "naïve & café"},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tc.query, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseQuery(%q) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestParseQueryEmpty(t *testing.T) {
+	for _, query := range []string{"", "   ", "&|!", "-cats", `-"big cats"`, `""`} {
+		if _, err := ParseQuery(query); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("ParseQuery(%q): got %v, want ErrEmptyQuery", query, err)
+		}
+	}
+}
@@ -3,24 +3,58 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/ChernakovEgor/chirpy/internal/auth"
 	"github.com/ChernakovEgor/chirpy/internal/database"
+	"github.com/ChernakovEgor/chirpy/internal/mail"
+	"github.com/ChernakovEgor/chirpy/internal/oidc"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	dbQueries      database.Queries
-	platform       string
-	jwtSecret      string
+	jwtKeys        *auth.KeyRing
 	polkaKey       string
+	tokenHashKey   []byte
+	mfaTokenKey    []byte
+	totpKey        []byte
+
+	trustProxyHeaders bool
+
+	accountLockout auth.LockoutPolicy
+	ipLockout      auth.LockoutPolicy
+
+	passwordHasher auth.PasswordHasher
+	passwordPolicy auth.PasswordPolicy
+	// dummyPasswordHash is checked when logging in to an unknown email,
+	// so that the response takes as long as for a wrong password.
+	dummyPasswordHash string
+
+	accountDeletionGrace time.Duration
+	// chirpEditWindow is how long after posting a chirp may be edited.
+	// Zero means forever.
+	chirpEditWindow time.Duration
+
+	oidcProviders map[string]*oidc.Provider
+
+	mailer                 mail.Sender
+	publicURL              string
+	unverifiedRestrictions map[string]bool
 }
 
 func (a *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -45,10 +79,6 @@ func (a *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
-	if a.platform != "dev" {
-		respondWithError(w, 403, "")
-		return
-	}
 	err := a.dbQueries.ResetUsers(context.Background())
 	if err != nil {
 		log.Fatalf("could not reset users: %v", err)
@@ -60,45 +90,331 @@ func (a *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
 func main() {
 	godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
-	platform := os.Getenv("PLATFORM")
 	jwtSecret := os.Getenv("JWT_SECRET")
+	jwtKeysDir := os.Getenv("JWT_KEYS_DIR")
+	jwtSigningKeyID := os.Getenv("JWT_SIGNING_KEY_ID")
 	polkaKey := os.Getenv("POLKA_KEY")
+	trustProxyHeaders := os.Getenv("TRUST_PROXY_HEADERS") == "true"
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:8080"
+	}
+	publicURL = strings.TrimSuffix(publicURL, "/")
+	unverifiedRestrictions, err := parseRestrictions(os.Getenv("UNVERIFIED_RESTRICTIONS"))
+	if err != nil {
+		log.Fatalf("could not parse UNVERIFIED_RESTRICTIONS: %v", err)
+	}
+	loginMaxFailures, err := envInt("LOGIN_MAX_FAILURES", 10)
+	if err != nil {
+		log.Fatalf("could not parse LOGIN_MAX_FAILURES: %v", err)
+	}
+	loginLockoutDuration, err := envDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	if err != nil {
+		log.Fatalf("could not parse LOGIN_LOCKOUT_DURATION: %v", err)
+	}
+	passwordHasher, err := newPasswordHasher()
+	if err != nil {
+		log.Fatalf("could not configure password hashing: %v", err)
+	}
+	dummyPassword, err := auth.MakeRandomToken()
+	if err != nil {
+		log.Fatalf("could not make dummy password: %v", err)
+	}
+	dummyPasswordHash, err := passwordHasher.Hash(dummyPassword)
+	if err != nil {
+		log.Fatalf("could not hash dummy password: %v", err)
+	}
+	passwordPolicy, err := newPasswordPolicy()
+	if err != nil {
+		log.Fatalf("could not configure password policy: %v", err)
+	}
+	accountDeletionGrace, err := envDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour)
+	if err != nil {
+		log.Fatalf("could not parse ACCOUNT_DELETION_GRACE: %v", err)
+	}
+	accountPurgeInterval, err := envDuration("ACCOUNT_PURGE_INTERVAL", time.Hour)
+	if err != nil || accountPurgeInterval <= 0 {
+		log.Fatalf("could not parse ACCOUNT_PURGE_INTERVAL: %v", err)
+	}
+	chirpEditWindow, err := envDuration("CHIRP_EDIT_WINDOW", 15*time.Minute)
+	if err != nil || chirpEditWindow < 0 {
+		log.Fatalf("could not parse CHIRP_EDIT_WINDOW: %v", err)
+	}
+	oidcProviders, err := newOIDCProviders(publicURL)
+	if err != nil {
+		log.Fatalf("could not configure OIDC providers: %v", err)
+	}
+	tokenHashKey := os.Getenv("TOKEN_HASH_KEY")
+	if tokenHashKey == "" {
+		log.Fatalf("TOKEN_HASH_KEY is not set")
+	}
+	totpKey := os.Getenv("TOTP_ENCRYPTION_KEY")
+	if totpKey == "" {
+		log.Fatalf("TOTP_ENCRYPTION_KEY is not set")
+	}
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("could not connect to db: %v", err)
 	}
 
+	jwtKeys := auth.NewKeyRing()
+	if jwtKeysDir != "" {
+		jwtKeys, err = auth.LoadKeyRing(jwtKeysDir, jwtSigningKeyID)
+		if err != nil {
+			log.Fatalf("could not load JWT keys: %v", err)
+		}
+	} else {
+		jwtKeys.Add(auth.NewHMACKey("", []byte(jwtSecret)))
+		jwtKeys.SetActive("")
+	}
+
 	dbQueries := database.New(db)
 	mux := http.NewServeMux()
-	apiCfg := apiConfig{dbQueries: *dbQueries, platform: platform, jwtSecret: jwtSecret, polkaKey: polkaKey}
+	apiCfg := apiConfig{
+		db:                db,
+		dbQueries:         *dbQueries,
+		jwtKeys:           jwtKeys,
+		polkaKey:          polkaKey,
+		tokenHashKey:      []byte(tokenHashKey),
+		mfaTokenKey:       auth.MFATokenKey([]byte(tokenHashKey)),
+		totpKey:           []byte(totpKey),
+		trustProxyHeaders: trustProxyHeaders,
+
+		accountLockout: auth.LockoutPolicy{
+			FreeAttempts:     3,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: loginMaxFailures,
+			LockoutDuration:  loginLockoutDuration,
+			ResetAfter:       time.Hour,
+		},
+		// addresses are never locked out since many users can share one
+		ipLockout: auth.LockoutPolicy{
+			FreeAttempts: 20,
+			BaseDelay:    time.Second,
+			MaxDelay:     5 * time.Minute,
+			ResetAfter:   time.Hour,
+		},
+
+		passwordHasher:    passwordHasher,
+		passwordPolicy:    passwordPolicy,
+		dummyPasswordHash: dummyPasswordHash,
+
+		accountDeletionGrace: accountDeletionGrace,
+		chirpEditWindow:      chirpEditWindow,
+
+		oidcProviders: oidcProviders,
+
+		mailer:                 newMailer(),
+		publicURL:              publicURL,
+		unverifiedRestrictions: unverifiedRestrictions,
+	}
+	apiCfg.promoteAdmins(splitList(os.Getenv("ADMIN_EMAILS")))
+	go apiCfg.purgeDeletedAccounts(accountPurgeInterval)
+
 	fileserverHandler := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
 
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(fileserverHandler))
 
 	mux.HandleFunc("GET /api/healthz", handleHealthz)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handleGetChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handleGetChirpByID)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handleJWKS)
+	mux.HandleFunc("GET /api/chirps", apiCfg.optionalAuth(auth.ScopeChirpsRead, apiCfg.handleGetChirps))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.optionalAuth(auth.ScopeChirpsRead, apiCfg.handleGetChirpByID))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.optionalAuth(auth.ScopeChirpsRead, apiCfg.handleGetChirpThread))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.optionalAuth(auth.ScopeChirpsRead, apiCfg.handleGetChirpRevisions))
+	mux.HandleFunc("GET /api/users/{id}/likes", apiCfg.optionalAuth(auth.ScopeChirpsRead, apiCfg.handleGetUserLikes))
+	mux.HandleFunc("GET /api/search/chirps", apiCfg.optionalAuth(auth.ScopeChirpsRead, apiCfg.handleSearchChirps))
+	mux.HandleFunc("GET /api/sessions", apiCfg.requireAuth(auth.ScopeAccountRead, apiCfg.handleGetSessions))
+	mux.HandleFunc("GET /api/oidc/identities", apiCfg.requireSession(apiCfg.handleGetOIDCIdentities))
+	mux.HandleFunc("GET /api/oidc/{provider}/login", apiCfg.handleOIDCLogin)
+	mux.HandleFunc("GET /api/oidc/{provider}/callback", apiCfg.handleOIDCCallback)
+	mux.HandleFunc("GET /api/account/activity", apiCfg.requireAuth(auth.ScopeAccountRead, apiCfg.handleGetAccountActivity))
+	mux.HandleFunc("GET /api/tokens", apiCfg.requireSession(apiCfg.handleGetPersonalAccessTokens))
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.requireSession(apiCfg.handleGetOAuthClients))
+	mux.HandleFunc("GET /api/oauth/authorize", apiCfg.requireSession(apiCfg.handleAuthorizeConsent))
 
-	mux.HandleFunc("POST /api/chirps", apiCfg.handleCreateChirp)
+	mux.HandleFunc("POST /api/chirps", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleCreateChirp))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleLikeChirp))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirps", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleRechirp))
 	mux.HandleFunc("POST /api/validate_chirp", handleValidate)
 	mux.HandleFunc("POST /api/users", apiCfg.handleUsers)
+	mux.HandleFunc("POST /api/users/verify", apiCfg.handleVerifyEmail)
+	mux.HandleFunc("POST /api/users/email/confirm", apiCfg.handleConfirmEmailChange)
+	mux.HandleFunc("POST /api/users/verify/resend", apiCfg.requireAuth(auth.ScopeAccountWrite, apiCfg.handleResendVerification))
 	mux.HandleFunc("POST /api/login", apiCfg.handleLogin)
+	mux.HandleFunc("POST /api/login/mfa", apiCfg.handleLoginMFA)
+	mux.HandleFunc("POST /api/mfa/totp", apiCfg.requireSession(apiCfg.handleEnrollTOTP))
+	mux.HandleFunc("POST /api/mfa/totp/confirm", apiCfg.requireSession(apiCfg.handleConfirmTOTP))
+	mux.HandleFunc("POST /api/password-reset/request", apiCfg.handlePasswordResetRequest)
+	mux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handlePasswordResetConfirm)
+	mux.HandleFunc("POST /api/oidc/{provider}/link", apiCfg.requireSession(apiCfg.handleOIDCLink))
 	mux.HandleFunc("POST /api/refresh", apiCfg.handleRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handleRevoke)
+	mux.HandleFunc("POST /api/logout-all", apiCfg.requireAuth(auth.ScopeAccountWrite, apiCfg.handleLogoutAll))
+	mux.HandleFunc("POST /api/tokens", apiCfg.requireSession(apiCfg.handleCreatePersonalAccessToken))
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.requireSession(apiCfg.handleCreateOAuthClient))
+	mux.HandleFunc("POST /api/oauth/authorize", apiCfg.requireSession(apiCfg.handleAuthorize))
+	mux.HandleFunc("POST /api/oauth/token", apiCfg.handleOAuthToken)
+	mux.HandleFunc("POST /api/oauth/introspect", apiCfg.handleIntrospect)
+	mux.HandleFunc("POST /api/oauth/revoke", apiCfg.handleOAuthRevoke)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlePolkaWebhook)
 
-	mux.HandleFunc("PUT /api/users", apiCfg.handleUpdateCredentials)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleUpdateChirp))
+	mux.HandleFunc("PUT /api/users/password", apiCfg.requireAuth(auth.ScopeAccountWrite, apiCfg.handleChangePassword))
+	mux.HandleFunc("PUT /api/users/email", apiCfg.requireAuth(auth.ScopeAccountWrite, apiCfg.handleRequestEmailChange))
 
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handleDeleteChirp)
+	mux.HandleFunc("DELETE /api/users", apiCfg.requireSession(apiCfg.handleDeleteAccount))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleDeleteChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleUnlikeChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirps", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleUndoRechirp))
+	mux.HandleFunc("DELETE /api/sessions/{id}", apiCfg.requireAuth(auth.ScopeAccountWrite, apiCfg.handleDeleteSession))
+	mux.HandleFunc("DELETE /api/mfa/totp", apiCfg.requireSession(apiCfg.handleDisableTOTP))
+	mux.HandleFunc("DELETE /api/oidc/identities/{id}", apiCfg.requireSession(apiCfg.handleDeleteOIDCIdentity))
+	mux.HandleFunc("DELETE /api/tokens/{id}", apiCfg.requireSession(apiCfg.handleDeletePersonalAccessToken))
+	mux.HandleFunc("DELETE /api/oauth/clients/{id}", apiCfg.requireSession(apiCfg.handleDeleteOAuthClient))
 
-	mux.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
-	mux.HandleFunc("POST /admin/reset", apiCfg.resetHandler)
+	mux.HandleFunc("GET /admin/metrics", apiCfg.requireRole(auth.RoleAdmin, apiCfg.metricsHandler))
+	mux.HandleFunc("GET /admin/audit-events", apiCfg.requireRole(auth.RoleAdmin, apiCfg.handleGetAuditEvents))
+	mux.HandleFunc("POST /admin/reset", apiCfg.requireRole(auth.RoleAdmin, apiCfg.resetHandler))
+	mux.HandleFunc("PUT /admin/users/{id}/role", apiCfg.requireRole(auth.RoleAdmin, apiCfg.handleSetUserRole))
+	mux.HandleFunc("DELETE /admin/lockouts", apiCfg.requireRole(auth.RoleAdmin, apiCfg.handleClearLockout))
 	server := http.Server{Addr: ":8080", Handler: mux}
 
 	log.Fatalln(server.ListenAndServe())
 }
 
+// newMailer picks the mail transport from the environment: MAIL_DIR writes
+// messages to files, SMTP_ADDR delivers them, and without either they are
+// only logged.
+func newMailer() mail.Sender {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "chirpy@localhost"
+	}
+
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return mail.DirSender{Dir: dir, From: from}
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return mail.SMTPSender{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	}
+
+	log.Println("no mail transport configured, emails will only be logged")
+	return mail.LogSender{}
+}
+
+// newPasswordHasher reads the algorithm for new password hashes and its
+// parameters from the environment. Stored hashes that do not match are
+// upgraded when their owners next log in.
+func newPasswordHasher() (auth.PasswordHasher, error) {
+	hasher := auth.DefaultPasswordHasher
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		hasher.Algorithm = algorithm
+	}
+	if hasher.Algorithm != auth.AlgArgon2id && hasher.Algorithm != auth.AlgBcrypt {
+		return hasher, fmt.Errorf("unknown PASSWORD_HASH_ALGORITHM %q", hasher.Algorithm)
+	}
+
+	memory, err := envInt("ARGON2_MEMORY_KIB", int(hasher.Argon2.Memory))
+	if err != nil || memory < 8 {
+		return hasher, errors.New("invalid ARGON2_MEMORY_KIB")
+	}
+	iterations, err := envInt("ARGON2_ITERATIONS", int(hasher.Argon2.Iterations))
+	if err != nil || iterations < 1 {
+		return hasher, errors.New("invalid ARGON2_ITERATIONS")
+	}
+	parallelism, err := envInt("ARGON2_PARALLELISM", int(hasher.Argon2.Parallelism))
+	if err != nil || parallelism < 1 || parallelism > 255 {
+		return hasher, errors.New("invalid ARGON2_PARALLELISM")
+	}
+	cost, err := envInt("BCRYPT_COST", hasher.BcryptCost)
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return hasher, errors.New("invalid BCRYPT_COST")
+	}
+
+	hasher.Argon2.Memory = uint32(memory)
+	hasher.Argon2.Iterations = uint32(iterations)
+	hasher.Argon2.Parallelism = uint8(parallelism)
+	hasher.BcryptCost = cost
+	return hasher, nil
+}
+
+// newPasswordPolicy reads the password rules from the environment.
+// BREACHED_PASSWORDS_FILE points at a list of SHA-1 hashes in the Have I
+// Been Pwned format; passwords in it are refused.
+func newPasswordPolicy() (auth.PasswordPolicy, error) {
+	policy := auth.DefaultPasswordPolicy
+
+	minLength, err := envInt("PASSWORD_MIN_LENGTH", policy.MinLength)
+	if err != nil || minLength < 1 {
+		return policy, errors.New("invalid PASSWORD_MIN_LENGTH")
+	}
+	minScore, err := envInt("PASSWORD_MIN_SCORE", policy.MinScore)
+	if err != nil || minScore < 0 || minScore > 4 {
+		return policy, errors.New("invalid PASSWORD_MIN_SCORE")
+	}
+	policy.MinLength = minLength
+	policy.MinScore = minScore
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		minCount, err := envInt("BREACHED_PASSWORDS_MIN_COUNT", 1)
+		if err != nil {
+			return policy, errors.New("invalid BREACHED_PASSWORDS_MIN_COUNT")
+		}
+		policy.Breached, err = auth.LoadBreachedPasswords(path, minCount)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	return policy, nil
+}
+
+func envInt(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func envDuration(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// splitList splits a comma separated environment variable.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseRestrictions(value string) (map[string]bool, error) {
+	restrictions := make(map[string]bool)
+	for _, name := range splitList(value) {
+		if !slices.Contains(knownRestrictions, name) {
+			return nil, fmt.Errorf("unknown restriction %q", name)
+		}
+		restrictions[name] = true
+	}
+	return restrictions, nil
+}
+
 func handleHealthz(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+)
+
+// checkPasswordPolicy answers with 422 and every rule password breaks, and
+// returns false, when password may not be used. userInputs are details of
+// the account, such as its email address, that make for guessable
+// passwords.
+func (a *apiConfig) checkPasswordPolicy(w http.ResponseWriter, password string, userInputs ...string) bool {
+	violations := a.passwordPolicy.Check(password, userInputs...)
+	if len(violations) == 0 {
+		return true
+	}
+
+	body := struct {
+		Error      string                 `json:"error"`
+		Violations []auth.PolicyViolation `json:"violations"`
+	}{
+		Error:      "password does not meet the password policy",
+		Violations: violations,
+	}
+	respondWithJSON(w, http.StatusUnprocessableEntity, body)
+	return false
+}
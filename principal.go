@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/ChernakovEgor/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+var (
+	errInsufficientScope = errors.New("insufficient scope")
+	errAccountDeleted    = errors.New("account is scheduled for deletion")
+)
+
+// principal is the authenticated caller of a request.
+type principal struct {
+	UserID uuid.UUID
+	// Scopes limits what the caller may do. It is nil for a user's own
+	// session, which may do everything.
+	Scopes []string
+	// TokenID is the personal access token the caller used, if any.
+	TokenID uuid.UUID
+	// Role comes from the access token. Personal access tokens and tokens
+	// issued to third-party clients are always limited to auth.RoleUser.
+	Role string
+	// ClientID is the third-party client acting for the user, if any.
+	ClientID string
+}
+
+func (p principal) hasScope(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope)
+}
+
+// isSession reports whether the caller logged in themselves rather than
+// presenting a delegated token.
+func (p principal) isSession() bool {
+	return p.Scopes == nil
+}
+
+// authenticate resolves the bearer token of r, which is an access token
+// from handleLogin, an access token issued to a third-party client, or a
+// personal access token, and checks that it grants scope. An empty scope
+// only requires a valid token.
+func (a *apiConfig) authenticate(r *http.Request, scope string) (principal, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return principal{}, err
+	}
+
+	var caller principal
+	if auth.IsPersonalAccessToken(token) {
+		caller, err = a.authenticatePersonalAccessToken(token)
+	} else {
+		var claims auth.Claims
+		claims, err = a.jwtKeys.ValidateAccessToken(token)
+		caller = principal{UserID: claims.UserID, Role: claims.Role, Scopes: claims.Scopes, ClientID: claims.ClientID}
+	}
+	if err != nil {
+		return principal{}, err
+	}
+
+	// Deleting an account revokes its refresh and personal access tokens,
+	// but access tokens stay valid until they expire. Logging in again
+	// restores the account and issues new ones.
+	user, err := a.dbQueries.GetUserByID(context.Background(), caller.UserID)
+	if err != nil {
+		return principal{}, err
+	}
+	if user.DeletedAt.Valid {
+		return principal{}, errAccountDeleted
+	}
+
+	if scope != "" && !caller.hasScope(scope) {
+		return principal{}, errInsufficientScope
+	}
+	return caller, nil
+}
+
+func (a *apiConfig) authenticatePersonalAccessToken(token string) (principal, error) {
+	candidates, err := a.dbQueries.GetPersonalAccessTokensByPrefix(context.Background(), auth.PersonalAccessTokenPrefix(token))
+	if err != nil {
+		return principal{}, err
+	}
+
+	for _, candidate := range candidates {
+		if !auth.CheckTokenHash(token, candidate.TokenHash, a.tokenHashKey) {
+			continue
+		}
+
+		err = a.dbQueries.TouchPersonalAccessToken(context.Background(), candidate.ID)
+		if err != nil {
+			log.Printf("could not update personal access token %v: %v", candidate.ID, err)
+		}
+		return principal{UserID: candidate.UserID, Scopes: candidate.Scopes, TokenID: candidate.ID, Role: auth.RoleUser}, nil
+	}
+	return principal{}, fmt.Errorf("unknown personal access token")
+}
+
+type principalKey struct{}
+
+// principalFrom returns the caller stored by the auth middleware. Handlers
+// behind optionalAuth get the zero principal for anonymous requests.
+func principalFrom(r *http.Request) principal {
+	caller, _ := r.Context().Value(principalKey{}).(principal)
+	return caller
+}
+
+func (p principal) isAnonymous() bool {
+	return p.UserID == uuid.Nil
+}
+
+// requireAuth wraps next so that it only runs for callers whose token
+// grants scope, and stores the caller in the request context. An empty
+// scope only requires a valid token.
+func (a *apiConfig) requireAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, err := a.authenticate(r, scope)
+		if err != nil {
+			respondWithAuthError(w, err, scope)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, caller)))
+	}
+}
+
+// optionalAuth is requireAuth for endpoints that also serve anonymous
+// callers. A token that is present but invalid, or does not grant scope,
+// is still rejected.
+func (a *apiConfig) optionalAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, err := a.authenticate(r, scope)
+		if errors.Is(err, auth.ErrNoAuthHeader) {
+			next(w, r)
+			return
+		}
+		if err != nil {
+			respondWithAuthError(w, err, scope)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, caller)))
+	}
+}
+
+// requireSession is requireAuth for endpoints that manage credentials, which
+// delegated tokens must not reach.
+func (a *apiConfig) requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return a.requireAuth("", func(w http.ResponseWriter, r *http.Request) {
+		if !principalFrom(r).isSession() {
+			respondWithError(w, http.StatusForbidden, "delegated tokens cannot be used here")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// requireRole is requireSession for callers with at least role. The role is
+// taken from the access token, so a change of role applies once the user's
+// current access tokens expire.
+func (a *apiConfig) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return a.requireSession(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.HasRole(principalFrom(r).Role, role) {
+			respondWithError(w, http.StatusForbidden, "insufficient role")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// respondWithAuthError answers a request that failed authentication with
+// the WWW-Authenticate challenge of RFC 6750.
+func respondWithAuthError(w http.ResponseWriter, err error, scope string) {
+	switch {
+	case errors.Is(err, auth.ErrNoAuthHeader):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy"`)
+		respondWithError(w, http.StatusUnauthorized, "authentication required")
+	case errors.Is(err, errInsufficientScope):
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", scope="%s"`, scope))
+		respondWithError(w, http.StatusForbidden, "token lacks the required scope")
+	case errors.Is(err, errAccountDeleted):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy", error="invalid_token"`)
+		respondWithError(w, http.StatusUnauthorized, "account is scheduled for deletion")
+	default:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy", error="invalid_token"`)
+		respondWithError(w, http.StatusUnauthorized, "token invalid")
+	}
+}
@@ -0,0 +1,32 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events(id, created_at, event, outcome, user_id, actor_id, ip_address, user_agent, details) VALUES
+(
+  gen_random_uuid(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7
+);
+
+-- name: ListUserAuditEvents :many
+SELECT *
+  FROM audit_events
+ WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- name: ListAuditEvents :many
+SELECT *
+  FROM audit_events
+ WHERE (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id)::uuid)
+   AND (sqlc.narg(event)::text IS NULL OR event = sqlc.narg(event)::text)
+   AND (sqlc.narg(outcome)::text IS NULL OR outcome = sqlc.narg(outcome)::text)
+   AND (sqlc.narg(ip_address)::text IS NULL OR ip_address = sqlc.narg(ip_address)::text)
+   AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since)::timestamp)
+   AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until)::timestamp)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
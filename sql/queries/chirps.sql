@@ -1,30 +1,144 @@
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id) VALUES (
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, in_reply_to, quote_of) VALUES (
   gen_random_uuid(), 
   NOW(),
   NOW(),
   $1,
-  $2
+  $2,
+  $3,
+  $4
 ) RETURNING *;
 
--- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id
+-- name: GetChirpByID :one
+SELECT *
   FROM chirps
-ORDER BY created_at;
+ WHERE id = $1
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL);
 
--- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id
+-- name: ListChirpsAsc :many
+SELECT *
   FROM chirps
- WHERE id = $1;
+ WHERE user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND (sqlc.narg(author_ids)::uuid[] IS NULL OR user_id = ANY(sqlc.narg(author_ids)::uuid[]))
+   AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since)::timestamp)
+   AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until)::timestamp)
+   AND (sqlc.narg(contains)::text IS NULL OR strpos(lower(body), lower(sqlc.narg(contains)::text)) > 0)
+   AND (sqlc.narg(has_media)::boolean IS NULL OR (body ~* 'https?://') = sqlc.narg(has_media)::boolean)
+   AND (NOT sqlc.arg(chirpy_red_only)::boolean OR user_id IN (SELECT id FROM users WHERE is_chirpy_red))
+   AND (sqlc.narg(after_created_at)::timestamp IS NULL
+        OR (created_at, id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY created_at, id
+LIMIT sqlc.arg(max_results);
 
--- name: GetChirpByAuthor :many
-SELECT id, created_at, updated_at, body, user_id
+-- name: ListChirpsDesc :many
+SELECT *
   FROM chirps
- WHERE user_id = $1
-ORDER BY created_at;
+ WHERE user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND (sqlc.narg(author_ids)::uuid[] IS NULL OR user_id = ANY(sqlc.narg(author_ids)::uuid[]))
+   AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since)::timestamp)
+   AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until)::timestamp)
+   AND (sqlc.narg(contains)::text IS NULL OR strpos(lower(body), lower(sqlc.narg(contains)::text)) > 0)
+   AND (sqlc.narg(has_media)::boolean IS NULL OR (body ~* 'https?://') = sqlc.narg(has_media)::boolean)
+   AND (NOT sqlc.arg(chirpy_red_only)::boolean OR user_id IN (SELECT id FROM users WHERE is_chirpy_red))
+   AND (sqlc.narg(after_created_at)::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
 
 -- name: DeleteChirp :one
 DELETE FROM chirps
  WHERE id = $2
    AND user_id = $1
   RETURNING *;
+
+-- name: DeleteChirpByID :one
+DELETE FROM chirps
+ WHERE id = $1
+  RETURNING *;
+
+-- name: GetChirpForUpdate :one
+SELECT *
+  FROM chirps
+ WHERE id = $1
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   FOR UPDATE;
+
+-- name: UpdateChirp :one
+UPDATE chirps
+   SET body = sqlc.arg(body),
+       updated_at = NOW()
+ WHERE id = sqlc.arg(id)
+   AND (sqlc.arg(edit_window_seconds)::int = 0
+        OR created_at > NOW() - make_interval(secs => sqlc.arg(edit_window_seconds)::int))
+RETURNING *;
+
+-- name: CreateChirpRevision :exec
+INSERT INTO chirp_revisions(id, chirp_id, body, written_at, replaced_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  NOW()
+);
+
+-- name: ListChirpRevisions :many
+SELECT *
+  FROM chirp_revisions
+ WHERE chirp_id = $1
+ORDER BY replaced_at DESC;
+
+-- name: ListChirpAncestors :many
+-- The ancestors of a chirp are the chirps whose thread_path its own starts
+-- with, which still holds for those above a deleted reply.
+SELECT *
+  FROM chirps
+ WHERE root_id = (SELECT x.root_id FROM chirps x WHERE x.id = $1)
+   AND thread_path = (SELECT x.thread_path[1:cardinality(chirps.thread_path)] FROM chirps x WHERE x.id = $1)
+   AND id <> $1
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+ORDER BY thread_path;
+
+-- name: ListChirpDescendants :many
+-- Descendants are found by thread_path rather than by following
+-- in_reply_to, so replies below a deleted reply are still part of the
+-- thread. Pages are keyed on the thread_path of the last reply.
+SELECT sqlc.embed(chirps), (cardinality(chirps.thread_path) - cardinality(parent.thread_path))::int AS depth
+  FROM chirps
+  JOIN chirps parent ON parent.root_id = chirps.root_id
+ WHERE parent.id = sqlc.arg(chirp_id)::uuid
+   AND cardinality(chirps.thread_path) > cardinality(parent.thread_path)
+   AND chirps.thread_path[1:cardinality(parent.thread_path)] = parent.thread_path
+   AND chirps.user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND (sqlc.narg(after_path)::text[] IS NULL OR chirps.thread_path > sqlc.narg(after_path)::text[])
+ORDER BY chirps.thread_path
+LIMIT sqlc.arg(max_results);
+
+-- name: ListChirpsByIDs :many
+SELECT *
+  FROM chirps
+ WHERE id = ANY(sqlc.arg(ids)::uuid[])
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL);
+
+-- name: CreateRechirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, rechirp_of) VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  '',
+  $1,
+  $2
+)
+ON CONFLICT (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: GetRechirp :one
+SELECT *
+  FROM chirps
+ WHERE user_id = $1
+   AND rechirp_of = $2;
+
+-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+ WHERE user_id = $1
+   AND rechirp_of = $2;
@@ -0,0 +1,31 @@
+-- name: CreateEmailChangeToken :one
+INSERT INTO email_change_tokens(id, user_id, new_email, token_prefix, token_hash, created_at, expires_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  NOW(),
+  NOW() + INTERVAL '24 hour'
+) RETURNING *;
+
+-- name: GetEmailChangeTokensByPrefix :many
+SELECT *
+  FROM email_change_tokens
+ WHERE token_prefix = $1
+   AND used_at IS NULL
+   AND expires_at > NOW();
+
+-- name: UseEmailChangeToken :execrows
+UPDATE email_change_tokens
+   SET used_at = NOW()
+ WHERE id = $1
+   AND used_at IS NULL
+   AND expires_at > NOW();
+
+-- name: InvalidateEmailChangeTokens :exec
+UPDATE email_change_tokens
+   SET used_at = NOW()
+ WHERE user_id = $1
+   AND used_at IS NULL;
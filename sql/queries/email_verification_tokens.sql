@@ -0,0 +1,23 @@
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens(id, user_id, token_prefix, token_hash, created_at, expires_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  NOW(),
+  NOW() + INTERVAL '24 hour'
+) RETURNING *;
+
+-- name: GetEmailVerificationTokensByPrefix :many
+SELECT *
+  FROM email_verification_tokens
+ WHERE token_prefix = $1
+   AND used_at IS NULL
+   AND expires_at > NOW();
+
+-- name: UseEmailVerificationToken :execrows
+UPDATE email_verification_tokens
+   SET used_at = NOW()
+ WHERE id = $1
+   AND used_at IS NULL;
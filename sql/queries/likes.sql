@@ -0,0 +1,30 @@
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes(user_id, chirp_id, created_at) VALUES
+(
+  $1,
+  $2,
+  NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+ WHERE user_id = $1
+   AND chirp_id = $2;
+
+-- name: ListLikedChirpIDs :many
+SELECT chirp_id
+  FROM chirp_likes
+ WHERE user_id = sqlc.arg(user_id)
+   AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: ListUserLikes :many
+SELECT sqlc.embed(chirps), chirp_likes.created_at AS liked_at
+  FROM chirps
+  JOIN chirp_likes ON chirp_likes.chirp_id = chirps.id
+ WHERE chirp_likes.user_id = sqlc.arg(user_id)
+   AND chirps.user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND (sqlc.narg(after_liked_at)::timestamp IS NULL
+        OR (chirp_likes.created_at, chirp_likes.chirp_id) < (sqlc.narg(after_liked_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY chirp_likes.created_at DESC, chirp_likes.chirp_id DESC
+LIMIT sqlc.arg(max_results);
@@ -0,0 +1,29 @@
+-- name: GetLoginThrottle :one
+SELECT failures,
+       GREATEST(COALESCE(CEIL(EXTRACT(EPOCH FROM (locked_until - NOW()))), 0), 0)::int AS retry_after_seconds
+  FROM login_throttles
+ WHERE key = $1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles(key, failures, last_failure_at) VALUES
+(
+  sqlc.arg(key),
+  1,
+  NOW()
+)
+ON CONFLICT (key) DO UPDATE
+   SET failures = CASE
+         WHEN login_throttles.last_failure_at < NOW() - make_interval(secs => sqlc.arg(reset_after_seconds)::int) THEN 1
+         ELSE login_throttles.failures + 1
+       END,
+       last_failure_at = NOW()
+RETURNING failures;
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+   SET locked_until = NOW() + make_interval(secs => sqlc.arg(lock_seconds)::int)
+ WHERE key = sqlc.arg(key);
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+ WHERE key = $1;
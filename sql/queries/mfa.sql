@@ -0,0 +1,55 @@
+-- name: UpsertTOTP :one
+INSERT INTO user_totp(user_id, secret, created_at) VALUES
+(
+  $1,
+  $2,
+  NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+   SET secret = EXCLUDED.secret,
+       created_at = NOW(),
+       last_used_counter = 0
+ WHERE user_totp.enabled_at IS NULL
+RETURNING *;
+
+-- name: GetTOTP :one
+SELECT *
+  FROM user_totp
+ WHERE user_id = $1;
+
+-- name: EnableTOTP :one
+UPDATE user_totp
+   SET enabled_at = NOW()
+ WHERE user_id = $1
+   AND enabled_at IS NULL
+RETURNING *;
+
+-- name: UseTOTPCounter :execrows
+UPDATE user_totp
+   SET last_used_counter = $2
+ WHERE user_id = $1
+   AND last_used_counter < $2;
+
+-- name: DeleteTOTP :exec
+DELETE FROM user_totp
+ WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO mfa_recovery_codes(id, user_id, code_hash, created_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  NOW()
+);
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+   SET used_at = NOW()
+ WHERE user_id = $1
+   AND code_hash = $2
+   AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+ WHERE user_id = $1;
@@ -0,0 +1,56 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients(id, owner_id, name, redirect_uris, scopes, secret_hash, created_at, updated_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  NOW(),
+  NOW()
+) RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT *
+  FROM oauth_clients
+ WHERE id = $1;
+
+-- name: ListOAuthClients :many
+SELECT *
+  FROM oauth_clients
+ WHERE owner_id = $1
+ORDER BY created_at;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+ WHERE id = $1
+   AND owner_id = $2;
+
+-- name: CreateAuthorizationCode :one
+INSERT INTO oauth_authorization_codes(id, code_prefix, code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, family_id, created_at, expires_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  gen_random_uuid(),
+  NOW(),
+  NOW() + INTERVAL '5 minute'
+) RETURNING *;
+
+-- name: GetAuthorizationCodesByPrefix :many
+SELECT *
+  FROM oauth_authorization_codes
+ WHERE code_prefix = $1
+   AND expires_at > NOW();
+
+-- name: UseAuthorizationCode :execrows
+UPDATE oauth_authorization_codes
+   SET used_at = NOW()
+ WHERE id = $1
+   AND used_at IS NULL;
@@ -0,0 +1,57 @@
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states(state, provider, nonce, code_verifier, link_user_id, created_at, expires_at) VALUES
+(
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  NOW(),
+  NOW() + INTERVAL '10 minute'
+);
+
+-- name: ConsumeOIDCLoginState :one
+DELETE FROM oidc_login_states
+ WHERE state = $1
+   AND provider = $2
+   AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLoginStates :exec
+DELETE FROM oidc_login_states
+ WHERE expires_at <= NOW();
+
+-- name: GetOIDCIdentity :one
+SELECT *
+  FROM oidc_identities
+ WHERE issuer = $1
+   AND subject = $2;
+
+-- name: CreateOIDCIdentity :one
+INSERT INTO oidc_identities(id, user_id, issuer, subject, email, created_at, last_login_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  NOW(),
+  NOW()
+) RETURNING *;
+
+-- name: TouchOIDCIdentity :exec
+UPDATE oidc_identities
+   SET last_login_at = NOW(),
+       email = $2
+ WHERE id = $1;
+
+-- name: ListOIDCIdentities :many
+SELECT *
+  FROM oidc_identities
+ WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteOIDCIdentity :execrows
+DELETE FROM oidc_identities
+ WHERE id = $1
+   AND user_id = $2;
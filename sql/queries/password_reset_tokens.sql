@@ -0,0 +1,30 @@
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens(id, user_id, token_prefix, token_hash, created_at, expires_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  NOW(),
+  NOW() + INTERVAL '30 minute'
+) RETURNING *;
+
+-- name: GetPasswordResetTokensByPrefix :many
+SELECT *
+  FROM password_reset_tokens
+ WHERE token_prefix = $1
+   AND used_at IS NULL
+   AND expires_at > NOW();
+
+-- name: UsePasswordResetToken :execrows
+UPDATE password_reset_tokens
+   SET used_at = NOW()
+ WHERE id = $1
+   AND used_at IS NULL
+   AND expires_at > NOW();
+
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+   SET used_at = NOW()
+ WHERE user_id = $1
+   AND used_at IS NULL;
@@ -0,0 +1,44 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens(id, user_id, name, token_prefix, token_hash, scopes, created_at, expires_at) VALUES
+(
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  NOW(),
+  $6
+) RETURNING *;
+
+-- name: GetPersonalAccessTokensByPrefix :many
+SELECT *
+  FROM personal_access_tokens
+ WHERE token_prefix = $1
+   AND revoked_at IS NULL
+   AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens
+   SET last_used_at = NOW()
+ WHERE id = $1;
+
+-- name: ListPersonalAccessTokens :many
+SELECT *
+  FROM personal_access_tokens
+ WHERE user_id = $1
+   AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: RevokePersonalAccessToken :execrows
+UPDATE personal_access_tokens
+   SET revoked_at = NOW()
+ WHERE id = $1
+   AND user_id = $2
+   AND revoked_at IS NULL;
+
+-- name: RevokeAllPersonalAccessTokens :exec
+UPDATE personal_access_tokens
+   SET revoked_at = NOW()
+ WHERE user_id = $1
+   AND revoked_at IS NULL;
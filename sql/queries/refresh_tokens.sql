@@ -1,22 +1,75 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at) VALUES
+INSERT INTO refresh_tokens(id, token_prefix, token_hash, created_at, updated_at, last_used_at, user_id, expires_at, family_id, user_agent, ip_address, client_id, scopes) VALUES
 (
+  gen_random_uuid(),
   $1,
+  $2,
   NOW(),
   NOW(),
-  $2,
-  NOW() + INTERVAL '60 day'
+  NOW(),
+  $3,
+  NOW() + INTERVAL '60 day',
+  $4,
+  $5,
+  $6,
+  $7,
+  $8
 ) RETURNING *;
 
--- name: GetUserByToken :one
-SELECT user_id
-  from refresh_tokens 
- WHERE revoked_at is NULL
-   AND token = $1;
+-- name: GetRefreshTokensByPrefix :many
+SELECT *
+  FROM refresh_tokens
+ WHERE token_prefix = $1
+   AND expires_at > NOW();
+
+-- name: ConsumeRefreshToken :one
+UPDATE refresh_tokens
+   SET consumed_at = NOW(),
+       updated_at = NOW()
+ WHERE id = $1
+   AND consumed_at IS NULL
+   AND revoked_at IS NULL
+RETURNING *;
 
 -- name: RevokeToken :one
 UPDATE refresh_tokens
    SET revoked_at = NOW(),
        updated_at = NOW()
- WHERE token = $1
+ WHERE id = $1
 RETURNING *;
+
+-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+   SET revoked_at = NOW(),
+       updated_at = NOW()
+ WHERE family_id = $1
+   AND revoked_at IS NULL;
+
+-- name: ListActiveSessions :many
+SELECT family_id,
+       (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = refresh_tokens.family_id)::timestamp AS started_at,
+       last_used_at,
+       expires_at,
+       user_agent,
+       ip_address
+  FROM refresh_tokens
+ WHERE user_id = $1
+   AND revoked_at IS NULL
+   AND consumed_at IS NULL
+   AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+   SET revoked_at = NOW(),
+       updated_at = NOW()
+ WHERE family_id = $1
+   AND user_id = $2
+   AND revoked_at IS NULL;
+
+-- name: RevokeAllUserTokens :exec
+UPDATE refresh_tokens
+   SET revoked_at = NOW(),
+       updated_at = NOW()
+ WHERE user_id = $1
+   AND revoked_at IS NULL;
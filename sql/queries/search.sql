@@ -0,0 +1,31 @@
+-- The snippet is built from the HTML-escaped body so that only the <mark>
+-- tags around matches are markup.
+
+-- name: SearchChirpsByRelevance :many
+SELECT sqlc.embed(chirps),
+       ts_rank_cd(search_vector, to_tsquery('english', sqlc.arg(query)::text))::real AS rank,
+       ts_headline('english', replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+                   to_tsquery('english', sqlc.arg(query)::text),
+                   'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=5, MaxWords=20')::text AS snippet
+  FROM chirps
+ WHERE search_vector @@ to_tsquery('english', sqlc.arg(query)::text)
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND (sqlc.narg(after_rank)::real IS NULL
+        OR (ts_rank_cd(search_vector, to_tsquery('english', sqlc.arg(query)::text))::real, created_at, id)
+           < (sqlc.narg(after_rank)::real, sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: SearchChirpsByRecency :many
+SELECT sqlc.embed(chirps),
+       ts_rank_cd(search_vector, to_tsquery('english', sqlc.arg(query)::text))::real AS rank,
+       ts_headline('english', replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+                   to_tsquery('english', sqlc.arg(query)::text),
+                   'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=5, MaxWords=20')::text AS snippet
+  FROM chirps
+ WHERE search_vector @@ to_tsquery('english', sqlc.arg(query)::text)
+   AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+   AND (sqlc.narg(after_created_at)::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
@@ -13,14 +13,14 @@ RETURNING id, created_at, updated_at, email;
 DELETE FROM users WHERE TRUE;
 
 -- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role, deleted_at
   FROM users
  WHERE users.email = $1;
 
--- name: UpdateEmailAndPassword :one
+-- name: UpdateEmail :one
 UPDATE users
    SET email = $2,
-       hashed_password = $3,
+       email_verified_at = NOW(),
        updated_at = NOW()
  WHERE id = $1
 RETURNING *;
@@ -31,3 +31,70 @@ UPDATE users
        updated_at = NOW()
  WHERE id = $1
 RETURNING *;
+
+-- name: GetUserByID :one
+SELECT *
+  FROM users
+ WHERE id = $1;
+
+-- name: MarkEmailVerified :one
+UPDATE users
+   SET email_verified_at = NOW(),
+       updated_at = NOW()
+ WHERE id = $1
+RETURNING *;
+
+-- name: UpdatePassword :one
+UPDATE users
+   SET hashed_password = $2,
+       updated_at = NOW()
+ WHERE id = $1
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+   SET role = $2,
+       updated_at = NOW()
+ WHERE id = $1
+RETURNING *;
+
+-- name: SetUserRoleByEmail :execrows
+-- Only verified addresses count, so that nobody can claim a role by
+-- signing up with someone else's address first.
+UPDATE users
+   SET role = $2,
+       updated_at = NOW()
+ WHERE email = $1
+   AND email_verified_at IS NOT NULL
+   AND deleted_at IS NULL;
+
+-- name: SoftDeleteUser :execrows
+UPDATE users
+   SET deleted_at = NOW(),
+       updated_at = NOW()
+ WHERE id = $1
+   AND deleted_at IS NULL;
+
+-- name: RestoreUser :one
+UPDATE users
+   SET deleted_at = NULL,
+       updated_at = NOW()
+ WHERE id = sqlc.arg(id)
+   AND deleted_at > NOW() - make_interval(secs => sqlc.arg(grace_seconds)::int)
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+ WHERE deleted_at < NOW() - make_interval(secs => sqlc.arg(grace_seconds)::int);
+
+-- name: CreateExternalUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, email_verified_at)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  '',
+  $2
+)
+RETURNING *;
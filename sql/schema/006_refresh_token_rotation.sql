@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+ADD COLUMN consumed_at TIMESTAMP DEFAULT NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens(family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN consumed_at,
+DROP COLUMN family_id;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Plaintext tokens cannot be hashed without the application key, so every
+-- existing session is dropped and users have to log in again.
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN token,
+ADD COLUMN id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+ADD COLUMN token_prefix TEXT NOT NULL,
+ADD COLUMN token_hash TEXT NOT NULL;
+
+CREATE INDEX refresh_tokens_token_prefix_idx ON refresh_tokens(token_prefix);
+
+-- +goose Down
+DELETE FROM refresh_tokens;
+
+DROP INDEX refresh_tokens_token_prefix_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN token_hash,
+DROP COLUMN token_prefix,
+DROP COLUMN id,
+ADD COLUMN token text NOT NULL;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP DEFAULT NULL;
+
+CREATE TABLE email_verification_tokens (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_prefix TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX email_verification_tokens_token_prefix_idx ON email_verification_tokens(token_prefix);
+
+-- +goose Down
+DROP TABLE email_verification_tokens;
+
+ALTER TABLE users
+DROP COLUMN email_verified_at;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE password_reset_tokens (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_prefix TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX password_reset_tokens_token_prefix_idx ON password_reset_tokens(token_prefix);
+
+-- +goose Down
+DROP TABLE password_reset_tokens;
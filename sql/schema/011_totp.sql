@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE user_totp (
+  user_id UUID PRIMARY KEY NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  secret TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  enabled_at TIMESTAMP DEFAULT NULL,
+  last_used_counter BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE mfa_recovery_codes (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX mfa_recovery_codes_user_id_idx ON mfa_recovery_codes(user_id);
+
+-- +goose Down
+DROP TABLE mfa_recovery_codes;
+DROP TABLE user_totp;
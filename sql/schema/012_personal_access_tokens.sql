@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE personal_access_tokens (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  token_prefix TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  scopes TEXT[] NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  last_used_at TIMESTAMP DEFAULT NULL,
+  expires_at TIMESTAMP DEFAULT NULL,
+  revoked_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX personal_access_tokens_token_prefix_idx ON personal_access_tokens(token_prefix);
+CREATE INDEX personal_access_tokens_user_id_idx ON personal_access_tokens(user_id);
+
+-- +goose Down
+DROP TABLE personal_access_tokens;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE email_change_tokens (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  new_email TEXT NOT NULL,
+  token_prefix TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX email_change_tokens_token_prefix_idx ON email_change_tokens(token_prefix);
+
+-- +goose Down
+DROP TABLE email_change_tokens;
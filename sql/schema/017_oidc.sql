@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE oidc_identities (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  last_login_at TIMESTAMP NOT NULL,
+  UNIQUE (issuer, subject)
+);
+
+CREATE INDEX oidc_identities_user_id_idx ON oidc_identities(user_id);
+
+CREATE TABLE oidc_login_states (
+  state TEXT PRIMARY KEY NOT NULL,
+  provider TEXT NOT NULL,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  link_user_id UUID DEFAULT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_login_states;
+DROP TABLE oidc_identities;
@@ -0,0 +1,44 @@
+-- +goose Up
+CREATE TABLE oauth_clients (
+  id UUID PRIMARY KEY NOT NULL,
+  owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  redirect_uris TEXT[] NOT NULL,
+  scopes TEXT[] NOT NULL,
+  secret_hash TEXT DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX oauth_clients_owner_id_idx ON oauth_clients(owner_id);
+
+CREATE TABLE oauth_authorization_codes (
+  id UUID PRIMARY KEY NOT NULL,
+  code_prefix TEXT NOT NULL,
+  code_hash TEXT NOT NULL,
+  client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  redirect_uri TEXT NOT NULL,
+  scopes TEXT[] NOT NULL,
+  code_challenge TEXT NOT NULL,
+  family_id UUID NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX oauth_authorization_codes_code_prefix_idx ON oauth_authorization_codes(code_prefix);
+
+-- Refresh tokens issued to third-party clients are limited to the scopes
+-- the user consented to. Tokens from a user's own logins have neither.
+ALTER TABLE refresh_tokens
+ADD COLUMN client_id UUID DEFAULT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+ADD COLUMN scopes TEXT[] DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN scopes,
+DROP COLUMN client_id;
+
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- user_id and actor_id deliberately have no foreign keys: the log must
+-- outlive purged accounts.
+CREATE TABLE audit_events (
+  id UUID PRIMARY KEY NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  event TEXT NOT NULL,
+  outcome TEXT NOT NULL CHECK (outcome IN ('success', 'failure')),
+  user_id UUID DEFAULT NULL,
+  actor_id UUID DEFAULT NULL,
+  ip_address TEXT NOT NULL,
+  user_agent TEXT NOT NULL,
+  details TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX audit_events_user_id_idx ON audit_events(user_id, created_at);
+CREATE INDEX audit_events_created_at_idx ON audit_events(created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TRIGGER audit_events_append_only ON audit_events;
+DROP FUNCTION audit_events_append_only();
+DROP TABLE audit_events;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- An expression index rather than a stored column: Postgres keeps it up to
+-- date on every insert and update, and chirp rows stay small. Queries must
+-- use exactly this expression for the index to apply.
+CREATE INDEX chirps_search_idx ON chirps USING GIN (to_tsvector('english', body));
+
+-- +goose Down
+DROP INDEX chirps_search_idx;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Each row is a body a chirp had before an edit: written_at is when it was
+-- posted or last edited, replaced_at when the edit replaced it.
+CREATE TABLE chirp_revisions (
+  id UUID PRIMARY KEY NOT NULL,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  body TEXT NOT NULL,
+  written_at TIMESTAMP NOT NULL,
+  replaced_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX chirp_revisions_chirp_id_idx ON chirp_revisions(chirp_id, replaced_at);
+
+-- +goose Down
+DROP TABLE chirp_revisions;
@@ -0,0 +1,58 @@
+-- +goose Up
+-- root_id identifies the conversation: the chirp that started it. It has no
+-- foreign key so that a conversation keeps its id when its first chirp is
+-- deleted. Replies to a deleted chirp lose their in_reply_to.
+ALTER TABLE chirps ADD COLUMN in_reply_to UUID DEFAULT NULL REFERENCES chirps(id) ON DELETE SET NULL;
+ALTER TABLE chirps ADD COLUMN root_id UUID;
+ALTER TABLE chirps ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE chirps SET root_id = id;
+ALTER TABLE chirps ALTER COLUMN root_id SET NOT NULL;
+
+CREATE INDEX chirps_in_reply_to_idx ON chirps(in_reply_to);
+CREATE INDEX chirps_root_id_idx ON chirps(root_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION chirps_set_root() RETURNS trigger AS $$
+BEGIN
+  IF NEW.in_reply_to IS NULL THEN
+    NEW.root_id := NEW.id;
+  ELSE
+    SELECT root_id INTO NEW.root_id FROM chirps WHERE id = NEW.in_reply_to;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_set_root
+BEFORE INSERT ON chirps
+FOR EACH ROW EXECUTE FUNCTION chirps_set_root();
+
+-- reply_count is kept up to date here so that reading it never needs a
+-- COUNT(*) over the replies
+-- +goose StatementBegin
+CREATE FUNCTION chirps_count_replies() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' AND NEW.in_reply_to IS NOT NULL THEN
+    UPDATE chirps SET reply_count = reply_count + 1 WHERE id = NEW.in_reply_to;
+  ELSIF TG_OP = 'DELETE' AND OLD.in_reply_to IS NOT NULL THEN
+    UPDATE chirps SET reply_count = reply_count - 1 WHERE id = OLD.in_reply_to;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_count_replies
+AFTER INSERT OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION chirps_count_replies();
+
+-- +goose Down
+DROP TRIGGER chirps_count_replies ON chirps;
+DROP FUNCTION chirps_count_replies();
+DROP TRIGGER chirps_set_root ON chirps;
+DROP FUNCTION chirps_set_root();
+ALTER TABLE chirps DROP COLUMN reply_count;
+ALTER TABLE chirps DROP COLUMN root_id;
+ALTER TABLE chirps DROP COLUMN in_reply_to;
@@ -0,0 +1,51 @@
+-- +goose Up
+-- A rechirp is a chirp with an empty body that reshares rechirp_of. It goes
+-- away with the chirp it reshares. A quote is a chirp of its own that
+-- refers to quote_of. Like root_id, quote_of has no foreign key, so a quote
+-- still shows that it quoted something after the original is deleted.
+ALTER TABLE chirps ADD COLUMN rechirp_of UUID DEFAULT NULL REFERENCES chirps(id) ON DELETE CASCADE;
+ALTER TABLE chirps ADD COLUMN quote_of UUID DEFAULT NULL;
+ALTER TABLE chirps ADD COLUMN rechirp_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chirps ADD COLUMN quote_count INTEGER NOT NULL DEFAULT 0;
+
+-- each user rechirps a chirp at most once
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_idx ON chirps(user_id, rechirp_of) WHERE rechirp_of IS NOT NULL;
+CREATE INDEX chirps_rechirp_of_idx ON chirps(rechirp_of);
+CREATE INDEX chirps_quote_of_idx ON chirps(quote_of);
+
+-- rechirp_count and quote_count are kept up to date here for the same
+-- reason as reply_count
+-- +goose StatementBegin
+CREATE FUNCTION chirps_count_shares() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    IF NEW.rechirp_of IS NOT NULL THEN
+      UPDATE chirps SET rechirp_count = rechirp_count + 1 WHERE id = NEW.rechirp_of;
+    END IF;
+    IF NEW.quote_of IS NOT NULL THEN
+      UPDATE chirps SET quote_count = quote_count + 1 WHERE id = NEW.quote_of;
+    END IF;
+  ELSE
+    IF OLD.rechirp_of IS NOT NULL THEN
+      UPDATE chirps SET rechirp_count = rechirp_count - 1 WHERE id = OLD.rechirp_of;
+    END IF;
+    IF OLD.quote_of IS NOT NULL THEN
+      UPDATE chirps SET quote_count = quote_count - 1 WHERE id = OLD.quote_of;
+    END IF;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_count_shares
+AFTER INSERT OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION chirps_count_shares();
+
+-- +goose Down
+DROP TRIGGER chirps_count_shares ON chirps;
+DROP FUNCTION chirps_count_shares();
+ALTER TABLE chirps DROP COLUMN quote_count;
+ALTER TABLE chirps DROP COLUMN rechirp_count;
+ALTER TABLE chirps DROP COLUMN quote_of;
+ALTER TABLE chirps DROP COLUMN rechirp_of;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- When the chirp replied to is deleted between the handler's check and the
+-- insert, there is no root_id to copy. Fail the way the in_reply_to
+-- foreign key would rather than with a NOT NULL violation on root_id.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirps_set_root() RETURNS trigger AS $$
+BEGIN
+  IF NEW.in_reply_to IS NULL THEN
+    NEW.root_id := NEW.id;
+  ELSE
+    SELECT root_id INTO NEW.root_id FROM chirps WHERE id = NEW.in_reply_to;
+    IF NOT FOUND THEN
+      RAISE EXCEPTION 'chirp % replied to does not exist', NEW.in_reply_to
+        USING ERRCODE = 'foreign_key_violation';
+    END IF;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirps_set_root() RETURNS trigger AS $$
+BEGIN
+  IF NEW.in_reply_to IS NULL THEN
+    NEW.root_id := NEW.id;
+  ELSE
+    SELECT root_id INTO NEW.root_id FROM chirps WHERE id = NEW.in_reply_to;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
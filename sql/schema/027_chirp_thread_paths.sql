@@ -0,0 +1,76 @@
+-- +goose Up
+-- thread_path places a chirp in its conversation: the sort key of every
+-- chirp on the way down from the root, ending with its own. Each key is
+-- the fixed width created_at followed by the id, so comparing paths as
+-- text sorts replies depth first, each level in the order they were
+-- posted. Unlike in_reply_to it never changes, so a reply stays in its
+-- place in the thread when a chirp above it is deleted.
+ALTER TABLE chirps ADD COLUMN thread_path TEXT[];
+
+-- Replies that already lost their in_reply_to are placed directly below
+-- the chirp that started their conversation.
+WITH RECURSIVE paths AS (
+  SELECT c.id,
+         CASE
+           WHEN c.id = c.root_id OR r.id IS NULL
+             THEN ARRAY[to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text]
+           ELSE ARRAY[to_char(r.created_at, 'YYYYMMDDHH24MISSUS') || r.id::text,
+                      to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text]
+         END AS path
+    FROM chirps c
+    LEFT JOIN chirps r ON r.id = c.root_id AND r.id <> c.id
+   WHERE c.in_reply_to IS NULL
+  UNION ALL
+  SELECT c.id, p.path || (to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text)
+    FROM chirps c
+    JOIN paths p ON c.in_reply_to = p.id
+)
+UPDATE chirps SET thread_path = paths.path
+  FROM paths
+ WHERE chirps.id = paths.id;
+
+ALTER TABLE chirps ALTER COLUMN thread_path SET NOT NULL;
+
+CREATE INDEX chirps_root_id_thread_path_idx ON chirps(root_id, thread_path);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirps_set_root() RETURNS trigger AS $$
+DECLARE
+  parent_path TEXT[];
+BEGIN
+  IF NEW.in_reply_to IS NULL THEN
+    NEW.root_id := NEW.id;
+    parent_path := ARRAY[]::TEXT[];
+  ELSE
+    SELECT root_id, thread_path INTO NEW.root_id, parent_path FROM chirps WHERE id = NEW.in_reply_to;
+    IF NOT FOUND THEN
+      RAISE EXCEPTION 'chirp % replied to does not exist', NEW.in_reply_to
+        USING ERRCODE = 'foreign_key_violation';
+    END IF;
+  END IF;
+  NEW.thread_path := parent_path || (to_char(NEW.created_at, 'YYYYMMDDHH24MISSUS') || NEW.id::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirps_set_root() RETURNS trigger AS $$
+BEGIN
+  IF NEW.in_reply_to IS NULL THEN
+    NEW.root_id := NEW.id;
+  ELSE
+    SELECT root_id INTO NEW.root_id FROM chirps WHERE id = NEW.in_reply_to;
+    IF NOT FOUND THEN
+      RAISE EXCEPTION 'chirp % replied to does not exist', NEW.in_reply_to
+        USING ERRCODE = 'foreign_key_violation';
+    END IF;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP INDEX chirps_root_id_thread_path_idx;
+ALTER TABLE chirps DROP COLUMN thread_path;
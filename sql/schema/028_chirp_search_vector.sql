@@ -0,0 +1,14 @@
+-- +goose Up
+-- The search vector is stored in a column of its own, generated from the
+-- body so that it is kept up to date on every insert and edit. Queries
+-- match and rank against the column instead of repeating the expression.
+ALTER TABLE chirps ADD COLUMN search_vector tsvector
+  GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX chirps_search_vector_idx ON chirps USING GIN (search_vector);
+DROP INDEX chirps_search_idx;
+
+-- +goose Down
+CREATE INDEX chirps_search_idx ON chirps USING GIN (to_tsvector('english', body));
+DROP INDEX chirps_search_vector_idx;
+ALTER TABLE chirps DROP COLUMN search_vector;